@@ -0,0 +1,8 @@
+CREATE TABLE "saved_search" (
+	"search_id" INTEGER PRIMARY KEY AUTOINCREMENT,
+	"username" TEXT,
+	"name" TEXT,
+	"term" TEXT,
+	"created" INTEGER
+);
+CREATE INDEX "idx_saved_search_username" ON "saved_search" ("username");
\ No newline at end of file
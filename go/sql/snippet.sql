@@ -4,9 +4,16 @@ CREATE TABLE "snippet" (
 	"username" TEXT NOT NULL DEFAULT '',
 	"description" TEXT NOT NULL DEFAULT '',
 	"created" INTEGER NOT NULL DEFAULT 0,
-	"updated" INTEGER NOT NULL DEFAULT 0
+	"updated" INTEGER NOT NULL DEFAULT 0,
+	"template" INTEGER NOT NULL DEFAULT 0,
+	"expires" INTEGER NOT NULL DEFAULT 0,
+	"deleted" INTEGER NOT NULL DEFAULT 0,
+	"archived" INTEGER NOT NULL DEFAULT 0,
+	"locked" INTEGER NOT NULL DEFAULT 0
 );
 CREATE UNIQUE INDEX "idx_snippet_search_id" ON "snippet" ("search_id");
 CREATE INDEX "idx_snippet_username" ON "snippet" ("username");
 CREATE INDEX "idx_snippet_created" ON "snippet" ("created");
-CREATE INDEX "idx_snippet_updated" ON "snippet" ("updated");
\ No newline at end of file
+CREATE INDEX "idx_snippet_updated" ON "snippet" ("updated");
+CREATE INDEX "idx_snippet_expires" ON "snippet" ("expires");
+CREATE INDEX "idx_snippet_deleted" ON "snippet" ("deleted");
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TABLE "snippet_author" (
+	"snippet_id" TEXT,
+	"username" TEXT,
+	"created" INTEGER,
+	PRIMARY KEY ("snippet_id", "username")
+);
+CREATE INDEX "idx_snippet_author_username" ON "snippet_author" ("username");
\ No newline at end of file
@@ -5,7 +5,10 @@ CREATE TABLE "snippet_comment" (
 	"markdown" TEXT,
 	"html" TEXT,
 	"created" INTEGER,
-	"updated" INTEGER
+	"updated" INTEGER,
+	"parent_id" INTEGER NOT NULL DEFAULT 0,
+	"deleted" INTEGER NOT NULL DEFAULT 0
 );
 CREATE INDEX "idx_snippet_comment_snippet_id" ON "snippet_comment" ("snippet_id");
-CREATE INDEX "idx_snippet_comment_created" ON "snippet_comment" ("created");
\ No newline at end of file
+CREATE INDEX "idx_snippet_comment_created" ON "snippet_comment" ("created");
+CREATE INDEX "idx_snippet_comment_parent_id" ON "snippet_comment" ("parent_id");
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE "snippet_comment_anchor" (
+	"comment_id" INTEGER PRIMARY KEY,
+	"snippet_id" TEXT,
+	"filename" TEXT,
+	"line_start" INTEGER,
+	"line_end" INTEGER,
+	"revision" TEXT
+);
+CREATE INDEX "idx_snippet_comment_anchor_snippet_id" ON "snippet_comment_anchor" ("snippet_id");
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE "snippet_comment_revision" (
+	"revision_id" INTEGER PRIMARY KEY AUTOINCREMENT,
+	"comment_id" INTEGER,
+	"markdown" TEXT,
+	"html" TEXT,
+	"created" INTEGER,
+	"replaced" INTEGER
+);
+CREATE INDEX "idx_snippet_comment_revision_comment_id" ON "snippet_comment_revision" ("comment_id");
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE "snippet_reaction" (
+	"snippet_id" TEXT,
+	"comment_id" INTEGER NOT NULL DEFAULT 0,
+	"username" TEXT,
+	"reaction" TEXT,
+	"created" INTEGER,
+	PRIMARY KEY ("snippet_id", "comment_id", "username", "reaction")
+);
\ No newline at end of file
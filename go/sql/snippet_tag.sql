@@ -0,0 +1,6 @@
+CREATE TABLE "snippet_tag" (
+	"snippet_id" TEXT,
+	"tag" TEXT,
+	PRIMARY KEY ("snippet_id", "tag")
+);
+CREATE INDEX "idx_snippet_tag_tag" ON "snippet_tag" ("tag");
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TABLE "snippet_thread_view" (
+	"snippet_id" TEXT,
+	"comment_id" INTEGER,
+	"username" TEXT,
+	PRIMARY KEY ("comment_id", "username")
+);
+CREATE INDEX "idx_snippet_thread_view_snippet_id" ON "snippet_thread_view" ("snippet_id");
\ No newline at end of file
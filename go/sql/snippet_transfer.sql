@@ -0,0 +1,7 @@
+CREATE TABLE "snippet_transfer" (
+	"snippet_id" TEXT PRIMARY KEY,
+	"from_username" TEXT,
+	"to_username" TEXT,
+	"created" INTEGER
+);
+CREATE INDEX "idx_snippet_transfer_to_username" ON "snippet_transfer" ("to_username");
\ No newline at end of file
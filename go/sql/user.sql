@@ -1,5 +1,6 @@
 CREATE TABLE "user" (
 	"username" TEXT PRIMARY KEY,
 	"display_name" TEXT,
-	"email" TEXT
+	"email" TEXT,
+	"expire_after" INTEGER NOT NULL DEFAULT 0
 );
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE "user_notification" (
+	"notification_id" INTEGER PRIMARY KEY AUTOINCREMENT,
+	"username" TEXT,
+	"type" TEXT,
+	"actor" TEXT,
+	"snippet_id" TEXT,
+	"comment_id" INTEGER NOT NULL DEFAULT 0,
+	"created" INTEGER,
+	"read" INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX "idx_user_notification_username" ON "user_notification" ("username");
+CREATE INDEX "idx_user_notification_snippet_id" ON "user_notification" ("snippet_id");
\ No newline at end of file
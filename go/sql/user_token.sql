@@ -0,0 +1,10 @@
+CREATE TABLE "user_token" (
+	"token_id" INTEGER PRIMARY KEY AUTOINCREMENT,
+	"token_hash" TEXT,
+	"username" TEXT,
+	"name" TEXT,
+	"created" INTEGER,
+	"last_used" INTEGER
+);
+CREATE UNIQUE INDEX "idx_user_token_token_hash" ON "user_token" ("token_hash");
+CREATE INDEX "idx_user_token_username" ON "user_token" ("username");
\ No newline at end of file
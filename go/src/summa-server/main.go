@@ -2,7 +2,9 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
+	"os"
 	"summa"
 )
 
@@ -10,6 +12,16 @@ var configFile string
 
 func init() {
 	flag.StringVar(&configFile, "f", "server.conf", "The server configuration file")
+	flag.Usage = usage
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-f server.conf] [command]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  serve          Start the HTTP server (default)\n")
+	fmt.Fprintf(os.Stderr, "  verify-index   Report differences between the search index and snippets\n")
+	fmt.Fprintf(os.Stderr, "  rebuild-index  Repair the search index from the snippets and their repositories\n\n")
+	flag.PrintDefaults()
 }
 
 func main() {
@@ -20,8 +32,39 @@ func main() {
 		log.Fatalf("Could not initialize Summa: %s", err)
 	}
 
-	summa.SetAuthProvider(auth)
-	summa.StartHttp()
+	switch flag.Arg(0) {
+	case "", "serve":
+		summa.SetAuthProvider(auth)
+		summa.StartJanitor()
+		summa.StartHttp()
+
+	case "verify-index":
+		searchIndex(false)
+
+	case "rebuild-index":
+		searchIndex(true)
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func searchIndex(repair bool) {
+	report, err := summa.VerifySearchIndex(repair)
+	if err != nil {
+		log.Fatalf("Could not verify search index: %s", err)
+	}
+
+	fmt.Printf("Checked:    %d snippets\n", report.Checked)
+	fmt.Printf("Missing:    %v\n", report.Missing)
+	fmt.Printf("Stale:      %v\n", report.Stale)
+	fmt.Printf("Unreadable: %v\n", report.Unreadable)
+	fmt.Printf("Orphaned:   %v\n", report.Orphaned)
+
+	if report.Repaired {
+		fmt.Println("Search index repaired")
+	}
 }
 
 func auth(username, password string) (*summa.User, error) {
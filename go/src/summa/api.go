@@ -38,19 +38,49 @@ var (
 	apiAuthEndpoint = "/api/auth/signin"
 
 	apiEndpoints = map[string]apiHandlerFunc{
-		"/api/auth/signout":    apiAuthSignout,
-		"/api/profile":         apiProfile,
-		"/api/profile/update":  apiProfileUpdate,
-		"/api/snippet":         apiSnippet,
-		"/api/snippet/create":  apiSnippetCreate,
-		"/api/snippet/update":  apiSnippetUpdate,
-		"/api/snippet/delete":  apiSnippetDelete,
-		"/api/comment/create":  apiCommentCreate,
-		"/api/comment/update":  apiCommentUpdate,
-		"/api/comment/delete":  apiCommentDelete,
-		"/api/snippets":        apiSnippets,
-		"/api/snippets/search": apiSnippetsSearch,
-		"/api/snippets/unread": apiSnippetsUnread,
+		"/api/auth/signout":            apiAuthSignout,
+		"/api/profile":                 apiProfile,
+		"/api/profile/update":          apiProfileUpdate,
+		"/api/profile/tokens":          apiProfileTokens,
+		"/api/profile/tokens/create":   apiProfileTokensCreate,
+		"/api/profile/tokens/delete":   apiProfileTokensDelete,
+		"/api/snippet":                 apiSnippet,
+		"/api/snippet/related":         apiSnippetRelated,
+		"/api/snippet/history":         apiSnippetHistory,
+		"/api/snippet/diff":            apiSnippetDiff,
+		"/api/snippet/embed":           apiSnippetEmbed,
+		"/api/snippet/create":          apiSnippetCreate,
+		"/api/snippet/update":          apiSnippetUpdate,
+		"/api/snippet/delete":          apiSnippetDelete,
+		"/api/snippet/restore":         apiSnippetRestore,
+		"/api/snippet/purge":           apiSnippetPurge,
+		"/api/snippet/archive":         apiSnippetArchive,
+		"/api/snippet/lock":            apiSnippetLock,
+		"/api/snippet/authors/add":     apiSnippetAuthorsAdd,
+		"/api/snippet/authors/remove":  apiSnippetAuthorsRemove,
+		"/api/snippet/transfer":        apiSnippetTransfer,
+		"/api/snippet/transfer/accept": apiSnippetTransferAccept,
+		"/api/snippet/transfer/cancel": apiSnippetTransferCancel,
+		"/api/comment/create":          apiCommentCreate,
+		"/api/comment/update":          apiCommentUpdate,
+		"/api/comment/delete":          apiCommentDelete,
+		"/api/comment/read":            apiCommentRead,
+		"/api/comment/history":         apiCommentHistory,
+		"/api/reaction/add":            apiReactionAdd,
+		"/api/reaction/remove":         apiReactionRemove,
+		"/api/snippets":                apiSnippets,
+		"/api/snippets/search":         apiSnippetsSearch,
+		"/api/snippets/unread":         apiSnippetsUnread,
+		"/api/trash":                   apiTrash,
+		"/api/transfers":               apiTransfers,
+		"/api/notifications":           apiNotifications,
+		"/api/notifications/read":      apiNotificationsRead,
+		"/api/search/saved":            apiSearchSaved,
+		"/api/search/saved/create":     apiSearchSavedCreate,
+		"/api/search/saved/delete":     apiSearchSavedDelete,
+		"/api/admin/search/verify":     apiAdminSearchVerify,
+		"/api/tags":                    apiTags,
+		"/api/languages":               apiLanguages,
 	}
 )
 
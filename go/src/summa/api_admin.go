@@ -0,0 +1,23 @@
+package summa
+
+import (
+	"database/sql"
+	_ "go-sqlite3"
+)
+
+func apiAdminSearchVerify(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	if !config.IsAdmin(req.Username) {
+		return &forbiddenError{"You do not have permission to verify the search index"}
+	}
+
+	repair, _ := req.Data["repair"].(bool)
+
+	report, err := searchIndexVerify(db, repair)
+	if err != nil {
+		return &internalServerError{"Could not verify search index", err}
+	}
+
+	resp["report"] = report
+
+	return nil
+}
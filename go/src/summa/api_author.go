@@ -0,0 +1,87 @@
+package summa
+
+import (
+	"database/sql"
+	_ "go-sqlite3"
+)
+
+// apiAuthorData checks the snippet and username given in a request to change
+// the co-authors of a snippet
+func apiAuthorData(db *sql.DB, req apiRequest) (*snippet, string, apiError) {
+	id, ok := req.Data["id"].(string)
+	if !ok {
+		return nil, "", &badRequestError{"The 'id' field must be a string"}
+	}
+
+	username, ok := req.Data["username"].(string)
+	if !ok {
+		return nil, "", &badRequestError{"The 'username' field must be a string"}
+	}
+
+	snip, err := snippetFetch(db, id)
+	if err != nil {
+		return nil, "", &internalServerError{"Could not fetch snippet", err}
+	}
+
+	if snip == nil {
+		return nil, "", &notFoundError{"No such snippet"}
+	}
+
+	return snip, username, nil
+}
+
+func apiSnippetAuthorsAdd(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	snip, username, apierr := apiAuthorData(db, req)
+	if apierr != nil {
+		return apierr
+	}
+
+	if snip.Username != req.Username && !config.IsAdmin(req.Username) {
+		return &forbiddenError{"You do not have permission to add authors to this snippet"}
+	}
+
+	exists, err := userExists(db, username)
+	if err != nil {
+		return &internalServerError{"Could not check if user exists", err}
+	}
+
+	if !exists || username == snip.Username {
+		return &conflictError{apiResponseData{"field": "username"}}
+	}
+
+	err = snippetAuthorAdd(db, snip.ID, username)
+	if err != nil {
+		return &internalServerError{"Could not add author", err}
+	}
+
+	resp["authors"], err = snippetAuthorsFetch(db, snip.ID)
+	if err != nil {
+		return &internalServerError{"Could not fetch authors", err}
+	}
+
+	return nil
+}
+
+func apiSnippetAuthorsRemove(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	snip, username, apierr := apiAuthorData(db, req)
+	if apierr != nil {
+		return apierr
+	}
+
+	// Co-authors may take themselves off a snippet
+	if snip.Username != req.Username && username != req.Username && !config.IsAdmin(req.Username) {
+		return &forbiddenError{"You do not have permission to remove authors from this snippet"}
+	}
+
+	err := snippetAuthorRemove(db, snip.ID, username)
+	if err != nil {
+		return &internalServerError{"Could not remove author", err}
+	}
+
+	resp["authors"], err = snippetAuthorsFetch(db, snip.ID)
+	if err != nil {
+		return &internalServerError{"Could not fetch authors", err}
+	}
+
+	return nil
+}
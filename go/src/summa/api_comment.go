@@ -23,11 +23,47 @@ func apiCommentCreate(db *sql.DB, req apiRequest, resp apiResponseData) apiError
 		return &badRequestError{"No such snippet"}
 	}
 
+	readOnly, err := snippetIsReadOnly(db, comment.SnippetID)
+	if err != nil {
+		return &internalServerError{"Could not check if snippet is read-only", err}
+	}
+
+	if readOnly {
+		return &lockedError{"This snippet is archived or locked and can't be commented on"}
+	}
+
 	comment.Markdown, _ = req.Data["message"].(string)
 	if strings.TrimSpace(comment.Markdown) == "" {
 		return &conflictError{apiResponseData{"field": "message"}}
 	}
 
+	switch req.Data["parentId"].(type) {
+	case nil:
+	case string:
+		parent, err := snippetCommentFetch(db, req.Data["parentId"].(string))
+		if err != nil {
+			return &internalServerError{"Could not fetch comment", err}
+		}
+
+		if parent == nil || parent.SnippetID != comment.SnippetID {
+			return &conflictError{apiResponseData{"field": "parentId"}}
+		}
+
+		// Replying to a reply continues the same thread
+		comment.ParentID = parent.ThreadID()
+	default:
+		return &badRequestError{"The 'parentId' field must be a string"}
+	}
+
+	// Replies belong wherever the comment they reply to was made
+	if _, ok := req.Data["file"]; ok && comment.ParentID == 0 {
+		var apierr apiError
+		comment.Anchor, apierr = apiValidateCommentAnchor(req, comment.SnippetID)
+		if apierr != nil {
+			return apierr
+		}
+	}
+
 	comment.Username = req.Username
 	err = snippetCommentCreate(db, &comment)
 	if err != nil {
@@ -37,10 +73,77 @@ func apiCommentCreate(db *sql.DB, req apiRequest, resp apiResponseData) apiError
 	comment.DisplayName = req.User.DisplayName
 	resp["comment"] = comment
 
+	apiCommentMarkUnread(db, &comment)
+
+	return nil
+}
+
+// apiValidateCommentAnchor checks the file, range of lines and revision a
+// comment is to be made on. The lines must exist in the file at the revision,
+// which is the latest one if none is given
+func apiValidateCommentAnchor(req apiRequest, snippetID string) (*snippetCommentAnchor, apiError) {
+	var anchor snippetCommentAnchor
+
+	anchor.Filename, _ = req.Data["file"].(string)
+	if anchor.Filename == "" {
+		return nil, &conflictError{apiResponseData{"field": "file"}}
+	}
+
+	rev, _ := req.Data["revision"].(string)
+	if rev == "" {
+		rev = "HEAD"
+	}
+
+	var err error
+	anchor.Revision, err = repoResolve(snippetID, rev)
+	if err != nil {
+		return nil, &internalServerError{"Could not find revision", err}
+	}
+
+	if anchor.Revision == "" {
+		return nil, &conflictError{apiResponseData{"field": "revision"}}
+	}
+
+	contents, err := repoReadFile(snippetID, anchor.Revision, anchor.Filename)
+	if err != nil {
+		return nil, &internalServerError{"Could not read snippet file", err}
+	}
+
+	if contents == nil || IsBinary(contents) {
+		return nil, &conflictError{apiResponseData{"field": "file"}}
+	}
+
+	lines := len(diffSplitLines(string(contents)))
+
+	line, _ := req.Data["line"].(float64)
+	anchor.LineStart = int(line)
+	if anchor.LineStart < 1 || anchor.LineStart > lines {
+		return nil, &conflictError{apiResponseData{"field": "line"}}
+	}
+
+	anchor.LineEnd = anchor.LineStart
+	switch req.Data["lineEnd"].(type) {
+	case nil:
+	case float64:
+		anchor.LineEnd = int(req.Data["lineEnd"].(float64))
+		if anchor.LineEnd < anchor.LineStart || anchor.LineEnd > lines {
+			return nil, &conflictError{apiResponseData{"field": "lineEnd"}}
+		}
+	default:
+		return nil, &conflictError{apiResponseData{"field": "lineEnd"}}
+	}
+
+	return &anchor, nil
+}
+
+// apiCommentMarkUnread marks the snippet and thread of a comment that was
+// just written as unread by everyone but its author
+func apiCommentMarkUnread(db *sql.DB, comment *snippetComment) {
 	snippetMarkUnread(db, comment.SnippetID)
 	snippetMarkReadBy(db, comment.SnippetID, comment.Username)
 
-	return nil
+	snippetThreadMarkUnread(db, comment.ThreadID())
+	snippetThreadMarkReadBy(db, comment.SnippetID, comment.ThreadID(), comment.Username)
 }
 
 func apiCommentUpdate(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
@@ -68,17 +171,65 @@ func apiCommentUpdate(db *sql.DB, req apiRequest, resp apiResponseData) apiError
 		return &internalServerError{"Could not fetch comment", err}
 	}
 
+	if comment.Deleted != 0 {
+		return &notFoundError{"No such comment"}
+	}
+
+	exists, err := snippetExists(db, comment.SnippetID)
+	if err != nil {
+		return &internalServerError{"Could not check if snippet exists", err}
+	}
+
+	if !exists {
+		return &notFoundError{"No such snippet"}
+	}
+
+	readOnly, err := snippetIsReadOnly(db, comment.SnippetID)
+	if err != nil {
+		return &internalServerError{"Could not check if snippet is read-only", err}
+	}
+
+	if readOnly {
+		return &lockedError{"This snippet is archived or locked and its comments can't be changed"}
+	}
+
+	// Comments have no revisions, only an updated timestamp
+	outdated, apierr := apiIsOutdated(req, comment.Updated, "")
+	if apierr == nil && req.Data["expectedRevision"] != nil {
+		apierr = &badRequestError{"Comments can only be checked against 'expectedUpdated'"}
+	}
+	if apierr != nil {
+		return apierr
+	}
+
+	if outdated {
+		return &editConflictError{
+			"This comment has been changed since you last fetched it",
+			apiResponseData{"comment": comment},
+		}
+	}
+
 	comment.Markdown = message
 
 	err = snippetCommentUpdate(db, comment)
+	if err == errEditConflict {
+		current, err := snippetCommentFetch(db, id)
+		if err != nil {
+			return &internalServerError{"Could not fetch comment", err}
+		}
+
+		return &editConflictError{
+			"This comment has been changed since you last fetched it",
+			apiResponseData{"comment": current},
+		}
+	}
 	if err != nil {
 		return &internalServerError{"Could not update comment", err}
 	}
 
 	resp["comment"] = comment
 
-	snippetMarkUnread(db, comment.SnippetID)
-	snippetMarkReadBy(db, comment.SnippetID, comment.Username)
+	apiCommentMarkUnread(db, comment)
 
 	return nil
 }
@@ -99,6 +250,29 @@ func apiCommentDelete(db *sql.DB, req apiRequest, resp apiResponseData) apiError
 		return &forbiddenError{"You do not have permission to delete this comment"}
 	}
 
+	comment, err := snippetCommentFetch(db, id)
+	if err != nil {
+		return &internalServerError{"Could not fetch comment", err}
+	}
+
+	exists, err := snippetExists(db, comment.SnippetID)
+	if err != nil {
+		return &internalServerError{"Could not check if snippet exists", err}
+	}
+
+	if !exists {
+		return &notFoundError{"No such snippet"}
+	}
+
+	readOnly, err := snippetIsReadOnly(db, comment.SnippetID)
+	if err != nil {
+		return &internalServerError{"Could not check if snippet is read-only", err}
+	}
+
+	if readOnly {
+		return &lockedError{"This snippet is archived or locked and its comments can't be changed"}
+	}
+
 	err = snippetCommentDelete(db, id)
 	if err != nil {
 		return &internalServerError{"Could not delete comment", err}
@@ -106,3 +280,54 @@ func apiCommentDelete(db *sql.DB, req apiRequest, resp apiResponseData) apiError
 
 	return nil
 }
+
+func apiCommentRead(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	id, ok := req.Data["id"].(string)
+
+	if !ok {
+		return &badRequestError{"The 'id' field must be a string"}
+	}
+
+	comment, err := snippetCommentFetch(db, id)
+	if err != nil {
+		return &internalServerError{"Could not fetch comment", err}
+	}
+
+	if comment == nil {
+		return &notFoundError{"No such comment"}
+	}
+
+	err = snippetThreadMarkReadBy(db, comment.SnippetID, comment.ThreadID(), req.Username)
+	if err != nil {
+		return &internalServerError{"Could not mark comments read", err}
+	}
+
+	return nil
+}
+
+func apiCommentHistory(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	id, ok := req.Data["id"].(string)
+
+	if !ok {
+		return &badRequestError{"The 'id' field must be a string"}
+	}
+
+	comment, err := snippetCommentFetch(db, id)
+	if err != nil {
+		return &internalServerError{"Could not fetch comment", err}
+	}
+
+	if comment == nil {
+		return &notFoundError{"No such comment"}
+	}
+
+	history, err := snippetCommentHistory(db, id)
+	if err != nil {
+		return &internalServerError{"Could not fetch comment history", err}
+	}
+
+	resp["comment"] = comment
+	resp["history"] = history
+
+	return nil
+}
@@ -117,6 +117,41 @@ func (e *conflictError) Data() apiResponseData {
 	return e.data
 }
 
+// 409, for changes based on an out of date version
+type editConflictError struct {
+	s    string
+	data apiResponseData
+}
+
+func (e *editConflictError) Error() string {
+	return e.s
+}
+
+func (e *editConflictError) Code() int {
+	return http.StatusConflict
+}
+
+func (e *editConflictError) Data() apiResponseData {
+	return e.data
+}
+
+// 423
+type lockedError struct {
+	s string
+}
+
+func (e *lockedError) Error() string {
+	return e.s
+}
+
+func (e *lockedError) Code() int {
+	return http.StatusLocked
+}
+
+func (e *lockedError) Data() apiResponseData {
+	return nil
+}
+
 // 500
 type internalServerError struct {
 	s   string
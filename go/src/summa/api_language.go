@@ -0,0 +1,12 @@
+package summa
+
+import (
+	"database/sql"
+	_ "go-sqlite3"
+)
+
+func apiLanguages(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	resp["languages"] = languages
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package summa
+
+import (
+	"database/sql"
+	_ "go-sqlite3"
+)
+
+func apiNotifications(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	unreadOnly, _ := req.Data["unread"].(bool)
+
+	list, err := notificationsFetch(db, req.Username, unreadOnly)
+	if err != nil {
+		return &internalServerError{"Could not fetch notifications", err}
+	}
+
+	unread, err := notificationsCountUnread(db, req.Username)
+	if err != nil {
+		return &internalServerError{"Could not count notifications", err}
+	}
+
+	resp["notifications"] = list
+	resp["unread"] = unread
+
+	return nil
+}
+
+func apiNotificationsRead(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	var ids []int64
+
+	switch req.Data["ids"].(type) {
+	case nil:
+	case []interface{}:
+		if len(req.Data["ids"].([]interface{})) == 0 {
+			return nil
+		}
+
+		for _, v := range req.Data["ids"].([]interface{}) {
+			id, ok := v.(float64)
+			if !ok {
+				return &conflictError{apiResponseData{"field": "ids"}}
+			}
+			ids = append(ids, int64(id))
+		}
+	default:
+		return &badRequestError{"The 'ids' field must be an array"}
+	}
+
+	err := notificationsMarkRead(db, req.Username, ids)
+	if err != nil {
+		return &internalServerError{"Could not mark notifications read", err}
+	}
+
+	return nil
+}
@@ -45,6 +45,21 @@ func apiProfileUpdate(db *sql.DB, req apiRequest, resp apiResponseData) apiError
 		return &conflictError{apiResponseData{"field": "email"}}
 	}
 
+	// Snippets created from now on expire this many
+	// milliseconds later, unless they say otherwise
+	u.ExpireAfter = req.User.ExpireAfter
+
+	switch req.Data["expireAfter"].(type) {
+	case nil:
+	case float64:
+		u.ExpireAfter = int64(req.Data["expireAfter"].(float64))
+		if u.ExpireAfter < 0 {
+			return &conflictError{apiResponseData{"field": "expireAfter"}}
+		}
+	default:
+		return &conflictError{apiResponseData{"field": "expireAfter"}}
+	}
+
 	err := userUpdate(db, &u)
 	if err != nil {
 		return &internalServerError{"Could not update user", err}
@@ -52,3 +67,56 @@ func apiProfileUpdate(db *sql.DB, req apiRequest, resp apiResponseData) apiError
 
 	return nil
 }
+
+func apiProfileTokens(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	tokens, err := userTokensFetch(db, req.Username)
+	if err != nil {
+		return &internalServerError{"Could not fetch tokens", err}
+	}
+
+	resp["tokens"] = tokens
+
+	return nil
+}
+
+func apiProfileTokensCreate(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	var t userToken
+
+	name, _ := req.Data["name"].(string)
+
+	t.Username = req.Username
+	t.Name = strings.TrimSpace(name)
+
+	if t.Name == "" {
+		return &conflictError{apiResponseData{"field": "name"}}
+	}
+
+	err := userTokenCreate(db, &t)
+	if err != nil {
+		return &internalServerError{"Could not create token", err}
+	}
+
+	// This is the only time the full token is ever sent
+	resp["token"] = t
+
+	return nil
+}
+
+func apiProfileTokensDelete(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	id, ok := req.Data["id"].(string)
+
+	if !ok {
+		return &badRequestError{"The 'id' field must be a string"}
+	}
+
+	deleted, err := userTokenDelete(db, req.Username, id)
+	if err != nil {
+		return &internalServerError{"Could not delete token", err}
+	}
+
+	if !deleted {
+		return &notFoundError{"No such token"}
+	}
+
+	return nil
+}
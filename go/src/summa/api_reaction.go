@@ -0,0 +1,112 @@
+package summa
+
+import (
+	"database/sql"
+	_ "go-sqlite3"
+)
+
+// apiReactionTarget works out what a reaction is left on, a snippet by its
+// 'snippetId' or a comment by its 'commentId', and which reaction it is
+func apiReactionTarget(db *sql.DB, req apiRequest) (string, int64, string, apiError) {
+	var snippetID string
+	var commentID int64
+
+	switch id := req.Data["commentId"].(type) {
+	case nil:
+		snippetID, _ = req.Data["snippetId"].(string)
+		if snippetID == "" {
+			return "", 0, "", &badRequestError{"The 'snippetId' or 'commentId' field must be given"}
+		}
+
+		exists, err := snippetExists(db, snippetID)
+		if err != nil {
+			return "", 0, "", &internalServerError{"Could not check if snippet exists", err}
+		}
+
+		if !exists {
+			return "", 0, "", &notFoundError{"No such snippet"}
+		}
+
+	case string:
+		comment, err := snippetCommentFetch(db, id)
+		if err != nil {
+			return "", 0, "", &internalServerError{"Could not fetch comment", err}
+		}
+
+		if comment == nil || comment.Deleted != 0 {
+			return "", 0, "", &notFoundError{"No such comment"}
+		}
+
+		exists, err := snippetExists(db, comment.SnippetID)
+		if err != nil {
+			return "", 0, "", &internalServerError{"Could not check if snippet exists", err}
+		}
+
+		if !exists {
+			return "", 0, "", &notFoundError{"No such snippet"}
+		}
+
+		snippetID, commentID = comment.SnippetID, comment.ID
+
+	default:
+		return "", 0, "", &badRequestError{"The 'commentId' field must be a string"}
+	}
+
+	reaction, _ := req.Data["reaction"].(string)
+	if !snippetReactionNames[reaction] {
+		return "", 0, "", &conflictError{apiResponseData{"field": "reaction"}}
+	}
+
+	readOnly, err := snippetIsReadOnly(db, snippetID)
+	if err != nil {
+		return "", 0, "", &internalServerError{"Could not check if snippet is read-only", err}
+	}
+
+	if readOnly {
+		return "", 0, "", &lockedError{"This snippet is archived or locked and can't be reacted to"}
+	}
+
+	return snippetID, commentID, reaction, nil
+}
+
+func apiReactionAdd(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	snippetID, commentID, reaction, apierr := apiReactionTarget(db, req)
+	if apierr != nil {
+		return apierr
+	}
+
+	err := snippetReactionAdd(db, snippetID, commentID, req.Username, reaction)
+	if err != nil {
+		return &internalServerError{"Could not add reaction", err}
+	}
+
+	reactions, err := snippetReactionsFetch(db, snippetID, commentID)
+	if err != nil {
+		return &internalServerError{"Could not fetch reactions", err}
+	}
+
+	resp["reactions"] = reactions
+
+	return nil
+}
+
+func apiReactionRemove(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	snippetID, commentID, reaction, apierr := apiReactionTarget(db, req)
+	if apierr != nil {
+		return apierr
+	}
+
+	err := snippetReactionRemove(db, snippetID, commentID, req.Username, reaction)
+	if err != nil {
+		return &internalServerError{"Could not remove reaction", err}
+	}
+
+	reactions, err := snippetReactionsFetch(db, snippetID, commentID)
+	if err != nil {
+		return &internalServerError{"Could not fetch reactions", err}
+	}
+
+	resp["reactions"] = reactions
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package summa
+
+import (
+	"database/sql"
+	_ "go-sqlite3"
+	"strings"
+)
+
+func apiSearchSaved(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	searches, err := savedSearchesFetchUnread(db, req.Username)
+	if err != nil {
+		return &internalServerError{"Could not fetch saved searches", err}
+	}
+
+	resp["searches"] = searches
+
+	return nil
+}
+
+func apiSearchSavedCreate(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	var search savedSearch
+
+	term, _ := req.Data["term"].(string)
+	name, _ := req.Data["name"].(string)
+
+	search.Username = req.Username
+	search.Term = strings.TrimSpace(term)
+	search.Name = strings.TrimSpace(name)
+
+	if search.Term == "" {
+		return &conflictError{apiResponseData{"field": "term"}}
+	}
+
+	if search.Name == "" {
+		search.Name = search.Term
+	}
+
+	// Make sure the term is something the full text index can match
+	_, err := snippetsSearch(db, snippetsOrderBy["updatedDesc"], search.Term, nil)
+	if err != nil {
+		return &conflictError{apiResponseData{"field": "term"}}
+	}
+
+	err = savedSearchCreate(db, &search)
+	if err != nil {
+		return &internalServerError{"Could not create saved search", err}
+	}
+
+	resp["search"] = search
+
+	return nil
+}
+
+func apiSearchSavedDelete(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	id, ok := req.Data["id"].(string)
+
+	if !ok {
+		return &badRequestError{"The 'id' field must be a string"}
+	}
+
+	owned, err := savedSearchIsOwnedBy(db, id, req.Username)
+	if err != nil {
+		return &internalServerError{"Could not check saved search ownership", err}
+	}
+
+	if !owned {
+		return &forbiddenError{"You do not have permission to delete this saved search"}
+	}
+
+	err = savedSearchDelete(db, id)
+	if err != nil {
+		return &internalServerError{"Could not delete saved search", err}
+	}
+
+	return nil
+}
@@ -2,12 +2,19 @@ package summa
 
 import (
 	"database/sql"
+	"encoding/base64"
 	"fmt"
 	_ "go-sqlite3"
-	"regexp"
+	"net/url"
+	"path"
 	"strings"
 )
 
+const (
+	SNIPPETS_RELATED_LIMIT_MAX     = 20
+	SNIPPETS_RELATED_LIMIT_DEFAULT = 5
+)
+
 func apiSnippet(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
 	id, ok := req.Data["id"].(string)
 
@@ -33,11 +40,49 @@ func apiSnippet(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
 		markRead = req.Data["markRead"].(string) != ""
 	}
 
+	unreadThreads, err := snippetThreadsUnread(db, id, req.Username)
+	if err != nil {
+		return &internalServerError{"Could not fetch unread comments", err}
+	}
+
+	for i := range snippet.Comments {
+		snippet.Comments[i].Unread = unreadThreads[snippet.Comments[i].ID]
+	}
+
+	for _, thread := range snippet.Review {
+		for i := range thread.Comments {
+			thread.Comments[i].Unread = unreadThreads[thread.Comments[i].ID]
+		}
+	}
+
 	if markRead {
 		err := snippetMarkReadBy(db, id, req.Username)
 		if err != nil {
 			return &internalServerError{"Could not mark snippet read", err}
 		}
+
+		err = snippetThreadsMarkReadBy(db, id, req.Username)
+		if err != nil {
+			return &internalServerError{"Could not mark comments read", err}
+		}
+	}
+
+	highlight, _ := req.Data["highlight"].(bool)
+	if highlight {
+		for i, file := range snippet.Files {
+			if file.HTML == "" {
+				snippet.Files[i].HTML = highlightFile(file.Language, file.Contents)
+			}
+		}
+	}
+
+	if snippet.Template {
+		snippet.Variables = templateVariables(snippet)
+	}
+
+	snippet.Transfer, err = snippetTransferFetch(db, id)
+	if err != nil {
+		return &internalServerError{"Could not fetch snippet transfer", err}
 	}
 
 	resp["snippet"] = snippet
@@ -45,12 +90,65 @@ func apiSnippet(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
 	return nil
 }
 
+func apiSnippetRelated(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	id, ok := req.Data["id"].(string)
+
+	if !ok {
+		return &badRequestError{"The 'id' field must be a string"}
+	}
+
+	limit, _ := req.Data["limit"].(float64)
+
+	switch {
+	case limit < 1:
+		limit = SNIPPETS_RELATED_LIMIT_DEFAULT
+
+	case limit > SNIPPETS_RELATED_LIMIT_MAX:
+		limit = SNIPPETS_RELATED_LIMIT_MAX
+	}
+
+	snip, err := snippetFetch(db, id)
+	if err != nil {
+		return &internalServerError{"Could not fetch snippet", err}
+	}
+
+	if snip == nil {
+		return &notFoundError{"No such snippet"}
+	}
+
+	related, err := snippetsRelated(db, snip, int(limit))
+	if err != nil {
+		return &internalServerError{"Could not fetch related snippets", err}
+	}
+
+	resp["snippets"] = related
+
+	return nil
+}
+
 func apiSnippetCreate(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	if _, ok := req.Data["templateId"]; ok {
+		apierr := apiApplyTemplate(db, req)
+		if apierr != nil {
+			return apierr
+		}
+	}
+
 	snip, apierr := apiValidateSnippetData(req)
 	if apierr != nil {
 		return apierr
 	}
 
+	for i, file := range snip.Files {
+		if file.Keep {
+			return &conflictError{apiResponseData{"field": fmt.Sprintf("file[%d].keep", i)}}
+		}
+	}
+
+	if _, ok := req.Data["expiresAt"]; !ok && req.User.ExpireAfter > 0 {
+		snip.ExpiresAt = UnixMilliseconds() + req.User.ExpireAfter
+	}
+
 	id, err := snippetCreate(db, snip, req.User)
 	if err != nil {
 		return &internalServerError{"Could not create snippet", err}
@@ -70,12 +168,12 @@ func apiSnippetUpdate(db *sql.DB, req apiRequest, resp apiResponseData) apiError
 		return &badRequestError{"The 'id' field must be a string"}
 	}
 
-	owned, err := snippetIsOwnedBy(db, id, req.Username)
+	canEdit, err := snippetCanEdit(db, id, req.Username)
 	if err != nil {
 		return &internalServerError{"Could not check snippet ownership", err}
 	}
 
-	if !owned {
+	if !canEdit {
 		return &forbiddenError{"You do not have permission to update this snippet"}
 	}
 
@@ -83,12 +181,142 @@ func apiSnippetUpdate(db *sql.DB, req apiRequest, resp apiResponseData) apiError
 	if err != nil {
 		return &internalServerError{"Could not fetch snippet", err}
 	}
+
+	if oldSnip.Archived || oldSnip.Locked {
+		return &lockedError{"This snippet is archived or locked and can't be changed"}
+	}
+
+	oldSnip.Revision, err = repoHead(id)
+	if err != nil {
+		return &internalServerError{"Could not fetch snippet revision", err}
+	}
+
+	outdated, apierr := apiIsOutdated(req, oldSnip.Updated, oldSnip.Revision)
+	if apierr != nil {
+		return apierr
+	}
+
+	// Files changed from an older revision are merged with the changes
+	// made since, rather than refused outright
+	baseRevision, _ := req.Data["expectedRevision"].(string)
+	merging := baseRevision != "" && baseRevision != oldSnip.Revision
+
+	if outdated && !merging {
+		return &editConflictError{
+			"This snippet has been changed since you last fetched it",
+			apiResponseData{"snippet": oldSnip},
+		}
+	}
+
 	newSnip, apierr := apiValidateSnippetData(req)
 	if apierr != nil {
 		return apierr
 	}
 
+	if _, ok := req.Data["template"]; !ok {
+		newSnip.Template = oldSnip.Template
+	}
+
+	if _, ok := req.Data["expiresAt"]; !ok {
+		newSnip.ExpiresAt = oldSnip.ExpiresAt
+	}
+
+	if _, ok := req.Data["tags"]; !ok {
+		newSnip.Tags = oldSnip.Tags
+	}
+
+	// Renames and files kept as they are refer to the files the client
+	// last fetched, which when merging are those of the base revision
+	var baseFiles snippetFiles
+	fetchedFiles := oldSnip.Files
+
+	if merging {
+		baseFiles, apierr = apiFetchMergeBase(id, baseRevision, oldSnip)
+		if apierr != nil {
+			return apierr
+		}
+
+		fetchedFiles = baseFiles
+	}
+
+	newSnip.Renames, apierr = apiValidateRenames(req, fetchedFiles, newSnip.Files)
+	if apierr != nil {
+		return apierr
+	}
+
+	renamedFrom := make(map[string]string)
+	for oldName, newName := range newSnip.Renames {
+		renamedFrom[newName] = oldName
+	}
+
+	// Files the client asked to keep as they are, such as binary
+	// files whose contents it was never sent, are read back from
+	// the repository
+	for i, file := range newSnip.Files {
+		if !file.Keep {
+			continue
+		}
+
+		oldName, ok := renamedFrom[file.Filename]
+		if !ok {
+			oldName = file.Filename
+		}
+
+		var oldFile *snippetFile
+		for j := range fetchedFiles {
+			if fetchedFiles[j].Filename == oldName {
+				oldFile = &fetchedFiles[j]
+			}
+		}
+
+		if oldFile == nil {
+			return &conflictError{apiResponseData{"field": fmt.Sprintf("file[%d].filename", i)}}
+		}
+
+		if merging {
+			newSnip.Files[i].Contents = oldFile.Contents
+		} else {
+			newSnip.Files[i].Contents, err = snippetFileRead(id, oldName)
+			if err != nil {
+				return &internalServerError{"Could not read snippet file", err}
+			}
+		}
+
+		newSnip.Files[i].Size = oldFile.Size
+		newSnip.Files[i].Binary = oldFile.Binary
+		newSnip.Files[i].MimeType = oldFile.MimeType
+
+		if file.Language == "" {
+			newSnip.Files[i].Language = oldFile.Language
+		}
+	}
+
+	if merging {
+		failed, apierr := apiMergeSnippetFiles(id, baseFiles, oldSnip, newSnip)
+		if apierr != nil {
+			return apierr
+		}
+
+		if len(failed) > 0 {
+			return &editConflictError{
+				"This snippet has been changed since you last fetched it and your changes could not be merged",
+				apiResponseData{"snippet": oldSnip, "conflicts": failed},
+			}
+		}
+	}
+
 	err = snippetUpdate(db, oldSnip, newSnip, req.User)
+	if err == errEditConflict {
+		current, err := snippetFetchAll(db, id)
+		if err != nil {
+			return &internalServerError{"Could not fetch snippet", err}
+		}
+
+		return &editConflictError{
+			"This snippet has been changed since you last fetched it",
+			apiResponseData{"snippet": current},
+		}
+	}
 	if err != nil {
 		return &internalServerError{"Could not update snippet", err}
 	}
@@ -97,12 +325,310 @@ func apiSnippetUpdate(db *sql.DB, req apiRequest, resp apiResponseData) apiError
 	snippetMarkReadBy(db, id, req.Username)
 
 	resp["snippet"] = oldSnip
+	resp["merged"] = merging
+
+	return nil
+}
+
+// apiFetchMergeBase will read the files of the latest revision of a snippet
+// that both the given revision and its current revision descend from, which
+// is the base an update made to the older revision is merged from
+func apiFetchMergeBase(id, revision string, oldSnip *snippet) (snippetFiles, apiError) {
+	base, err := repoMergeBase(id, revision, oldSnip.Revision)
+	if err != nil {
+		return nil, &internalServerError{"Could not find common revision", err}
+	}
+
+	if base == "" {
+		return nil, &conflictError{apiResponseData{"field": "expectedRevision"}}
+	}
+
+	baseFiles, err := repoReadFiles(id, base)
+	if err != nil {
+		return nil, &internalServerError{"Could not read snippet files", err}
+	}
+
+	// Files read from git lack what is worked out about them when
+	// saved, which is taken from the current files where it can be
+	for i, file := range baseFiles {
+		head := []byte(file.Contents)
+		if len(head) > FILE_SNIFF_LEN {
+			head = head[:FILE_SNIFF_LEN]
+		}
+
+		baseFiles[i].Binary = IsBinary(head)
+		baseFiles[i].MimeType = fileMimeType(file.Filename, head)
+		baseFiles[i].Language = languageDetect(file.Filename, file.Contents)
+
+		for _, oldFile := range oldSnip.Files {
+			if oldFile.Filename == file.Filename {
+				baseFiles[i].Language = oldFile.Language
+			}
+		}
+	}
+
+	return baseFiles, nil
+}
+
+// apiMergeSnippetFiles will merge the files of an update made to an older
+// revision of a snippet with the changes made since, from the files of the
+// revision both descend from. The files of newSnip are replaced with the
+// merged files, or the files that couldn't be merged are returned
+func apiMergeSnippetFiles(id string, baseFiles snippetFiles, oldSnip, newSnip *snippet) ([]snippetFileMerge, apiError) {
+	currentFiles, err := repoReadFiles(id, oldSnip.Revision)
+	if err != nil {
+		return nil, &internalServerError{"Could not read snippet files", err}
+	}
+
+	// Files read from git lack what was worked out about them when saved
+	for i, file := range currentFiles {
+		for _, oldFile := range oldSnip.Files {
+			if oldFile.Filename == file.Filename {
+				currentFiles[i].Language = oldFile.Language
+				currentFiles[i].Binary = oldFile.Binary
+				currentFiles[i].MimeType = oldFile.MimeType
+			}
+		}
+	}
+
+	merged, failed := mergeFiles(baseFiles, newSnip.Files, currentFiles, newSnip.Renames)
+	if len(failed) > 0 {
+		return failed, nil
+	}
+
+	newSnip.Files = merged
+
+	return nil, nil
+}
+
+// apiIsOutdated checks the optional 'expectedUpdated' timestamp and
+// 'expectedRevision' id of a request against the current version of a
+// snippet or comment, returning true if either of them doesn't match
+func apiIsOutdated(req apiRequest, updated int64, revision string) (bool, apiError) {
+	switch req.Data["expectedUpdated"].(type) {
+	case nil:
+	case float64:
+		if int64(req.Data["expectedUpdated"].(float64)) != updated {
+			return true, nil
+		}
+	default:
+		return false, &badRequestError{"The 'expectedUpdated' field must be a number"}
+	}
+
+	switch req.Data["expectedRevision"].(type) {
+	case nil:
+	case string:
+		if req.Data["expectedRevision"].(string) != revision {
+			return true, nil
+		}
+	default:
+		return false, &badRequestError{"The 'expectedRevision' field must be a string"}
+	}
+
+	return false, nil
+}
+
+// apiApplyTemplate fills in the description, files and tags missing from a
+// request to create a snippet with those of the template it names, after
+// substituting the variables given in the request for their placeholders
+func apiApplyTemplate(db *sql.DB, req apiRequest) apiError {
+	templateID, ok := req.Data["templateId"].(string)
+	if !ok {
+		return &badRequestError{"The 'templateId' field must be a string"}
+	}
+
+	vars := make(map[string]string)
+
+	switch req.Data["variables"].(type) {
+	case nil:
+	case map[string]interface{}:
+		for name, v := range req.Data["variables"].(map[string]interface{}) {
+			value, ok := v.(string)
+			if !ok {
+				return &conflictError{apiResponseData{"field": fmt.Sprintf("variables.%s", name)}}
+			}
+			vars[name] = value
+		}
+	default:
+		return &badRequestError{"The 'variables' field must be an object"}
+	}
+
+	tmpl, err := snippetFetch(db, templateID)
+	if err != nil {
+		return &internalServerError{"Could not fetch template", err}
+	}
+
+	if tmpl == nil {
+		return &notFoundError{"No such template"}
+	}
+
+	if !tmpl.Template {
+		return &conflictError{apiResponseData{"field": "templateId"}}
+	}
+
+	if _, ok := req.Data["description"]; !ok {
+		req.Data["description"] = templateSubstitute(tmpl.Description, vars)
+	}
+
+	if _, ok := req.Data["files"]; !ok {
+		var files []interface{}
+
+		for _, file := range tmpl.Files {
+			contents, err := snippetFileRead(tmpl.ID, file.Filename)
+			if err != nil {
+				return &internalServerError{"Could not read template file", err}
+			}
+
+			data := map[string]interface{}{
+				"filename": templateSubstitute(file.Filename, vars),
+				"language": file.Language,
+			}
+
+			// Binary files are copied as they are
+			if file.Binary {
+				data["contents"] = base64.StdEncoding.EncodeToString([]byte(contents))
+				data["encoding"] = "base64"
+			} else {
+				data["contents"] = templateSubstitute(contents, vars)
+			}
+
+			files = append(files, data)
+		}
+
+		req.Data["files"] = files
+	}
+
+	if _, ok := req.Data["tags"]; !ok {
+		var tags []interface{}
+		for _, tag := range tmpl.Tags {
+			tags = append(tags, tag)
+		}
+		req.Data["tags"] = tags
+	}
+
+	return nil
+}
+
+// apiValidateRenames checks the optional mapping of old to new filenames sent
+// with an update. Each old file may only be renamed once, to a file that is in
+// the update and isn't taken by another file that is kept
+func apiValidateRenames(req apiRequest, oldFiles, newFiles snippetFiles) (map[string]string, apiError) {
+	renames := make(map[string]string)
+
+	if _, ok := req.Data["renames"]; !ok {
+		return renames, nil
+	}
+
+	data, ok := req.Data["renames"].(map[string]interface{})
+	if !ok {
+		return nil, &badRequestError{"The 'renames' field must be an object"}
+	}
+
+	targets := make(map[string]bool)
+
+	for oldName, value := range data {
+		newName, ok := value.(string)
+		if !ok {
+			return nil, &badRequestError{"The 'renames' field must map filenames to filenames"}
+		}
+
+		if !oldFiles.contains(oldName) || !newFiles.contains(newName) || targets[newName] {
+			return nil, &conflictError{apiResponseData{"field": "renames"}}
+		}
+
+		targets[newName] = true
+		renames[oldName] = newName
+	}
+
+	for _, newName := range renames {
+		_, renamed := renames[newName]
+		if oldFiles.contains(newName) && !renamed {
+			return nil, &conflictError{apiResponseData{"field": "renames"}}
+		}
+	}
+
+	return renames, nil
+}
+
+func apiSnippetHistory(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	id, ok := req.Data["id"].(string)
+
+	if !ok {
+		return &badRequestError{"The 'id' field must be a string"}
+	}
+
+	exists, err := snippetExists(db, id)
+	if err != nil {
+		return &internalServerError{"Could not check snippet", err}
+	}
+
+	if !exists {
+		return &notFoundError{"No such snippet"}
+	}
+
+	commits, err := repoHistory(id)
+	if err != nil {
+		return &internalServerError{"Could not read snippet history", err}
+	}
+
+	revisions := make([]apiResponseData, 0, len(commits))
+	for _, commit := range commits {
+		// Patches are left to the diff endpoint
+		changes, err := repoDiff(id, commit.ParentID, commit.ID, false)
+		if err != nil {
+			return &internalServerError{"Could not compare snippet revisions", err}
+		}
+
+		revisions = append(revisions, apiResponseData{
+			"id":          commit.ID,
+			"parentId":    commit.ParentID,
+			"author":      commit.AuthorName,
+			"authorEmail": commit.AuthorEmail,
+			"time":        commit.Time.UnixNano() / 1e6,
+			"changes":     changes,
+		})
+	}
+
+	resp["revisions"] = revisions
+
+	return nil
+}
+
+func apiSnippetDiff(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	id, ok := req.Data["id"].(string)
+
+	if !ok {
+		return &badRequestError{"The 'id' field must be a string"}
+	}
+
+	from, _ := req.Data["from"].(string)
+	to, _ := req.Data["to"].(string)
+
+	exists, err := snippetExists(db, id)
+	if err != nil {
+		return &internalServerError{"Could not check snippet", err}
+	}
+
+	if !exists {
+		return &notFoundError{"No such snippet"}
+	}
+
+	changes, err := repoDiff(id, from, to, true)
+	if err != nil {
+		return &internalServerError{"Could not compare snippet revisions", err}
+	}
+
+	if changes == nil {
+		return &notFoundError{"No such revision"}
+	}
+
+	resp["changes"] = changes
 
 	return nil
 }
 
 func apiValidateSnippetData(req apiRequest) (*snippet, apiError) {
-	reqForFiles := []string{"filename", "language", "contents"}
+	reqForFiles := []string{"filename", "contents"}
 	var snip snippet
 
 	snip.Description, _ = req.Data["description"].(string)
@@ -110,12 +636,12 @@ func apiValidateSnippetData(req apiRequest) (*snippet, apiError) {
 		return nil, &conflictError{apiResponseData{"field": "description"}}
 	}
 
-	fileRegex := regexp.MustCompile("(?i)^[a-z0-9_.-]+$")
 	var files snippetFiles
 
+	filenames := make(map[string]bool)
+
 	switch req.Data["files"].(type) {
 	case []interface{}:
-		filenames := make(map[string]bool)
 		for i, v := range req.Data["files"].([]interface{}) {
 			switch v.(type) {
 			case map[string]interface{}:
@@ -123,7 +649,14 @@ func apiValidateSnippetData(req apiRequest) (*snippet, apiError) {
 				fields := make(map[string]string)
 				var file snippetFile
 
-				for _, required := range reqForFiles {
+				file.Keep, _ = vmap["keep"].(bool)
+
+				fileFields := reqForFiles
+				if file.Keep {
+					fileFields = []string{"filename"}
+				}
+
+				for _, required := range fileFields {
 					strVal, ok := vmap[required].(string)
 					if !ok || strings.TrimSpace(strVal) == "" {
 						return nil, &conflictError{apiResponseData{"field": fmt.Sprintf("file[%d].%s", i, required)}}
@@ -133,15 +666,64 @@ func apiValidateSnippetData(req apiRequest) (*snippet, apiError) {
 
 				lcFilename := strings.ToLower(fields["filename"])
 				_, ok := filenames[lcFilename]
-				if !fileRegex.MatchString(fields["filename"]) || ok {
+				if !snippetFilenameIsValid(fields["filename"]) || ok {
 					return nil, &conflictError{apiResponseData{"field": fmt.Sprintf("file[%d].filename", i)}}
 				}
 
 				filenames[lcFilename] = true
 
 				file.Filename = fields["filename"]
-				file.Language = fields["language"]
-				file.Contents = fields["contents"]
+
+				if !file.Keep {
+					encoding, _ := vmap["encoding"].(string)
+					switch encoding {
+					case "", "utf-8":
+						file.Contents = fields["contents"]
+
+					case "base64":
+						decoded, err := base64.StdEncoding.DecodeString(fields["contents"])
+						if err != nil {
+							return nil, &conflictError{apiResponseData{"field": fmt.Sprintf("file[%d].contents", i)}}
+						}
+						file.Contents = string(decoded)
+
+					default:
+						return nil, &conflictError{apiResponseData{"field": fmt.Sprintf("file[%d].encoding", i)}}
+					}
+
+					file.Size = int64(len(file.Contents))
+					if file.Size > config.MaxFileSize {
+						return nil, &conflictError{apiResponseData{"field": fmt.Sprintf("file[%d].contents", i)}}
+					}
+
+					head := []byte(file.Contents)
+					if len(head) > FILE_SNIFF_LEN {
+						head = head[:FILE_SNIFF_LEN]
+					}
+					file.Binary = IsBinary(head)
+					file.MimeType = fileMimeType(file.Filename, head)
+				}
+
+				// Normalize the language the client provides, and
+				// detect it when there isn't one or it's one we don't
+				// know, such as those saved before the registry
+				lang, _ := vmap["language"].(string)
+				file.Language = languageNormalize(lang)
+
+				switch {
+				case file.Language != "":
+
+				case file.Keep:
+
+				case file.Binary:
+					file.Language = languageDetectFilename(file.Filename)
+					if file.Language == "" {
+						file.Language = LANG_TEXT
+					}
+
+				default:
+					file.Language = languageDetect(file.Filename, file.Contents)
+				}
 
 				files = append(files, file)
 			default:
@@ -152,6 +734,64 @@ func apiValidateSnippetData(req apiRequest) (*snippet, apiError) {
 		return nil, &conflictError{apiResponseData{"field": "files"}}
 	}
 
+	// A path can't be used for both a file and a directory
+	for i, file := range files {
+		lcFilename := strings.ToLower(file.Filename)
+		for dir := path.Dir(lcFilename); dir != "."; dir = path.Dir(dir) {
+			if filenames[dir] {
+				return nil, &conflictError{apiResponseData{"field": fmt.Sprintf("file[%d].filename", i)}}
+			}
+		}
+	}
+
+	tags := make(map[string]bool)
+
+	switch req.Data["tags"].(type) {
+	case nil:
+	case []interface{}:
+		for i, v := range req.Data["tags"].([]interface{}) {
+			tag, ok := v.(string)
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if !ok || !snippetTagIsValid(tag) {
+				return nil, &conflictError{apiResponseData{"field": fmt.Sprintf("tags[%d]", i)}}
+			}
+
+			if tags[tag] {
+				continue
+			}
+
+			tags[tag] = true
+			snip.Tags = append(snip.Tags, tag)
+		}
+
+		if len(snip.Tags) > SNIPPET_TAGS_MAX {
+			return nil, &conflictError{apiResponseData{"field": "tags"}}
+		}
+	default:
+		return nil, &conflictError{apiResponseData{"field": "tags"}}
+	}
+
+	switch req.Data["template"].(type) {
+	case nil:
+	case bool:
+		snip.Template = req.Data["template"].(bool)
+	default:
+		return nil, &conflictError{apiResponseData{"field": "template"}}
+	}
+
+	// Snippets that expire are deleted by the janitor
+	// once the time given in milliseconds has passed
+	switch req.Data["expiresAt"].(type) {
+	case nil:
+	case float64:
+		snip.ExpiresAt = int64(req.Data["expiresAt"].(float64))
+		if snip.ExpiresAt != 0 && snip.ExpiresAt <= UnixMilliseconds() {
+			return nil, &conflictError{apiResponseData{"field": "expiresAt"}}
+		}
+	default:
+		return nil, &conflictError{apiResponseData{"field": "expiresAt"}}
+	}
+
 	snip.Files = files
 	snip.Username = req.Username
 
@@ -181,3 +821,89 @@ func apiSnippetDelete(db *sql.DB, req apiRequest, resp apiResponseData) apiError
 
 	return nil
 }
+
+func apiSnippetEmbed(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	id, ok := req.Data["id"].(string)
+
+	if !ok {
+		return &badRequestError{"The 'id' field must be a string"}
+	}
+
+	exists, err := snippetExists(db, id)
+	if err != nil {
+		return &internalServerError{"Could not check snippet", err}
+	}
+
+	if !exists {
+		return &notFoundError{"No such snippet"}
+	}
+
+	resp["html"] = embedPath(id, ".html")
+	resp["js"] = embedPath(id, ".js")
+	resp["oembed"] = "/oembed?url=" + url.QueryEscape(embedPath(id, ".html"))
+
+	return nil
+}
+
+// apiFetchOwnedOrAdmin fetches the snippet named by the request for its owner
+// or an administrator
+func apiFetchOwnedOrAdmin(db *sql.DB, req apiRequest) (*snippet, apiError) {
+	id, ok := req.Data["id"].(string)
+
+	if !ok {
+		return nil, &badRequestError{"The 'id' field must be a string"}
+	}
+
+	snip, err := snippetFetch(db, id)
+	if err != nil {
+		return nil, &internalServerError{"Could not fetch snippet", err}
+	}
+
+	if snip == nil {
+		return nil, &notFoundError{"No such snippet"}
+	}
+
+	if snip.Username != req.Username && !config.IsAdmin(req.Username) {
+		return nil, &forbiddenError{"You do not have permission to change this snippet"}
+	}
+
+	return snip, nil
+}
+
+func apiSnippetArchive(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	snip, apierr := apiFetchOwnedOrAdmin(db, req)
+	if apierr != nil {
+		return apierr
+	}
+
+	archived, ok := req.Data["archived"].(bool)
+	if !ok {
+		return &conflictError{apiResponseData{"field": "archived"}}
+	}
+
+	err := snippetSetArchived(db, snip.ID, archived)
+	if err != nil {
+		return &internalServerError{"Could not archive snippet", err}
+	}
+
+	return nil
+}
+
+func apiSnippetLock(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	snip, apierr := apiFetchOwnedOrAdmin(db, req)
+	if apierr != nil {
+		return apierr
+	}
+
+	locked, ok := req.Data["locked"].(bool)
+	if !ok {
+		return &conflictError{apiResponseData{"field": "locked"}}
+	}
+
+	err := snippetSetLocked(db, snip.ID, locked)
+	if err != nil {
+		return &internalServerError{"Could not lock snippet", err}
+	}
+
+	return nil
+}
@@ -3,34 +3,77 @@ package summa
 import (
 	"database/sql"
 	_ "go-sqlite3"
+	"regexp"
 	"strings"
+	"time"
 )
 
 const (
 	SNIPPETS_LIMIT_MAX     = 200
 	SNIPPETS_LIMIT_DEFAULT = 100
+
+	SNIPPETS_GREP_TIMEOUT     = 5 * time.Second
+	SNIPPETS_GREP_RESULTS_MAX = 100
+
+	// The number of upvotes of the snippet on each row of a listing
+	SNIPPETS_UPVOTES = "(SELECT COUNT(*) FROM snippet_reaction sr WHERE sr.snippet_id=s.snippet_id " +
+		"AND sr.comment_id=0 AND sr.reaction='" + REACTION_UPVOTE + "')"
 )
 
 var (
 	snippetsOrderBy = map[string]string{
-		"commentsAsc":     "num_comments",
-		"commentsDesc":    "num_comments DESC",
-		"filesAsc":        "num_files",
-		"filesDesc":       "num_files DESC",
+		"commentsAsc":     "comments",
+		"commentsDesc":    "comments DESC",
+		"filesAsc":        "files",
+		"filesDesc":       "files DESC",
 		"createdAsc":      "s.created",
 		"createdDesc":     "s.created DESC",
 		"updatedAsc":      "s.updated",
 		"updatedDesc":     "s.updated DESC",
 		"descriptionAsc":  "s.description",
 		"descriptionDesc": "s.description DESC",
+		"upvotesAsc":      SNIPPETS_UPVOTES,
+		"upvotesDesc":     SNIPPETS_UPVOTES + " DESC",
 	}
 )
 
+// apiSnippetsOrderBy returns the ORDER BY clause for the ordering named in
+// a request, ignoring case, or most recently updated first by default
+func apiSnippetsOrderBy(req apiRequest) string {
+	orderBy, _ := req.Data["orderBy"].(string)
+
+	for name, clause := range snippetsOrderBy {
+		if strings.EqualFold(name, orderBy) {
+			return clause
+		}
+	}
+
+	return snippetsOrderBy["updatedDesc"] + ", " + snippetsOrderBy["createdDesc"]
+}
+
+// apiSnippetsFilter builds the filter for a snippet listing
+// from the optional fields of the request
+func apiSnippetsFilter(req apiRequest) *snippetsFilter {
+	var filter snippetsFilter
+
+	filter.Username, _ = req.Data["username"].(string)
+	filter.Tag, _ = req.Data["tag"].(string)
+	filter.Tag = strings.ToLower(strings.TrimSpace(filter.Tag))
+
+	lang, _ := req.Data["language"].(string)
+	filter.Language = languageNormalize(lang)
+	if filter.Language == "" {
+		filter.Language = strings.TrimSpace(lang)
+	}
+
+	filter.Templates, _ = req.Data["templates"].(bool)
+
+	return &filter
+}
+
 func apiSnippets(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
 	start, _ := req.Data["start"].(float64)
 	limit, _ := req.Data["limit"].(float64)
-	orderBy, _ := req.Data["orderBy"].(string)
-	username, _ := req.Data["username"].(string)
 
 	if start < 1 {
 		start = 1
@@ -44,47 +87,128 @@ func apiSnippets(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
 		limit = SNIPPETS_LIMIT_MAX
 	}
 
-	orderBy, _ = snippetsOrderBy[strings.ToLower(orderBy)]
-	if orderBy == "" {
-		orderBy = snippetsOrderBy["updatedDesc"] + ", " + snippetsOrderBy["createdDesc"]
-	}
+	orderBy := apiSnippetsOrderBy(req)
+	filter := apiSnippetsFilter(req)
 
-	snips, err := snippetsFetch(db, start, limit, orderBy, username)
+	includeArchived, _ := req.Data["includeArchived"].(bool)
+	filter.HideArchived = !includeArchived
+
+	snips, err := snippetsFetch(db, start, limit, orderBy, filter)
 	if err != nil {
 		return &internalServerError{"Could not fetch snippets", err}
 	}
 
+	langs, err := snippetsCountLanguages(db, "", nil, nil, filter)
+	if err != nil {
+		return &internalServerError{"Could not count snippet languages", err}
+	}
+
 	resp["snippets"] = snips
+	resp["languages"] = langs
 
 	return nil
 }
 
 func apiSnippetsSearch(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
 	term, _ := req.Data["term"].(string)
-	orderBy, _ := req.Data["orderBy"].(string)
+	orderBy := apiSnippetsOrderBy(req)
+
+	mode, _ := req.Data["mode"].(string)
+	ignoreCase, _ := req.Data["ignoreCase"].(bool)
+	filter := apiSnippetsFilter(req)
+
+	var pattern string
+	switch mode {
+	case "", "fts":
+		snips, err := snippetsSearch(db, orderBy, term, filter)
+		if err != nil {
+			return &internalServerError{"Could not fetch snippets", err}
+		}
+
+		langs, err := snippetsCountLanguages(
+			db,
+			"JOIN snippet_search ss ON ss.docid=s.search_id",
+			[]string{"ss.snippet MATCH(?)"},
+			[]interface{}{term},
+			filter,
+		)
+		if err != nil {
+			return &internalServerError{"Could not count snippet languages", err}
+		}
+
+		resp["snippets"] = snips
+		resp["languages"] = langs
+
+		return nil
+
+	case "regex":
+		pattern = term
+
+	case "substring":
+		pattern = regexp.QuoteMeta(term)
+
+	default:
+		return &conflictError{apiResponseData{"field": "mode"}}
+	}
+
+	if term == "" {
+		return &conflictError{apiResponseData{"field": "term"}}
+	}
 
-	orderBy, _ = snippetsOrderBy[strings.ToLower(orderBy)]
-	if orderBy == "" {
-		orderBy = snippetsOrderBy["updatedDesc"] + ", " + snippetsOrderBy["createdDesc"]
+	if ignoreCase {
+		pattern = "(?i)" + pattern
 	}
 
-	snips, err := snippetsSearch(db, orderBy, term)
+	re, err := regexp.Compile(pattern)
 	if err != nil {
-		return &internalServerError{"Could not fetch snippets", err}
+		return &conflictError{apiResponseData{"field": "term"}}
+	}
+
+	deadline := time.Now().Add(SNIPPETS_GREP_TIMEOUT)
+	snips, truncated, err := snippetsGrep(db, orderBy, filter, re, deadline, SNIPPETS_GREP_RESULTS_MAX)
+	if err != nil {
+		return &internalServerError{"Could not search snippets", err}
+	}
+
+	var ids []interface{}
+	for _, snip := range *snips {
+		ids = append(ids, snip.ID)
+	}
+
+	langs, err := snippetsCountLanguages(
+		db,
+		"",
+		[]string{"s.snippet_id IN (" + sqlPlaceholders(len(ids)) + ")"},
+		ids,
+		nil,
+	)
+	if err != nil {
+		return &internalServerError{"Could not count snippet languages", err}
 	}
 
 	resp["snippets"] = snips
+	resp["languages"] = langs
+	resp["truncated"] = truncated
 
 	return nil
 }
 
 func apiSnippetsUnread(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
-	snippets, err := snippetsUnread(db, req.Username)
+	filter := apiSnippetsFilter(req)
+	filter.HideArchived = true
+
+	snippets, err := snippetsUnread(db, req.Username, filter)
 	if err != nil {
 		return &internalServerError{"Could not fetch snippets", err}
 	}
 
+	searches, err := savedSearchesFetchUnread(db, req.Username)
+	if err != nil {
+		return &internalServerError{"Could not fetch saved searches", err}
+	}
+
 	resp["snippets"] = snippets
+	resp["savedSearches"] = searches
 
 	return nil
 }
@@ -0,0 +1,34 @@
+package summa
+
+import (
+	"database/sql"
+	_ "go-sqlite3"
+	"strings"
+)
+
+const (
+	TAGS_LIMIT_MAX     = 200
+	TAGS_LIMIT_DEFAULT = 50
+)
+
+func apiTags(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	prefix, _ := req.Data["prefix"].(string)
+	limit, _ := req.Data["limit"].(float64)
+
+	switch {
+	case limit < 1:
+		limit = TAGS_LIMIT_DEFAULT
+
+	case limit > TAGS_LIMIT_MAX:
+		limit = TAGS_LIMIT_MAX
+	}
+
+	tags, err := tagsFetch(db, strings.ToLower(strings.TrimSpace(prefix)), int(limit))
+	if err != nil {
+		return &internalServerError{"Could not fetch tags", err}
+	}
+
+	resp["tags"] = tags
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package summa
+
+import (
+	"database/sql"
+	"fmt"
+	_ "go-sqlite3"
+)
+
+func apiTransfers(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	transfers, err := snippetTransfersFetch(db, req.Username)
+	if err != nil {
+		return &internalServerError{"Could not fetch transfers", err}
+	}
+
+	resp["transfers"] = transfers
+
+	return nil
+}
+
+// apiSnippetTransfer asks another user to take over a snippet. Administrators
+// may hand over the snippets of those who have left
+func apiSnippetTransfer(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	snip, username, apierr := apiAuthorData(db, req)
+	if apierr != nil {
+		return apierr
+	}
+
+	if snip.Username != req.Username && !config.IsAdmin(req.Username) {
+		return &forbiddenError{"You do not have permission to transfer this snippet"}
+	}
+
+	exists, err := userExists(db, username)
+	if err != nil {
+		return &internalServerError{"Could not check if user exists", err}
+	}
+
+	if !exists || username == snip.Username {
+		return &conflictError{apiResponseData{"field": "username"}}
+	}
+
+	t := snippetTransfer{
+		SnippetID:   snip.ID,
+		Description: snip.Description,
+		From:        snip.Username,
+		To:          username,
+	}
+
+	err = snippetTransferRequest(db, &t)
+	if err != nil {
+		return &internalServerError{"Could not request transfer", err}
+	}
+
+	resp["transfer"] = t
+
+	return nil
+}
+
+// apiFetchTransfer fetches the pending transfer of the snippet named by a request
+func apiFetchTransfer(db *sql.DB, req apiRequest) (*snippetTransfer, apiError) {
+	id, ok := req.Data["id"].(string)
+	if !ok {
+		return nil, &badRequestError{"The 'id' field must be a string"}
+	}
+
+	t, err := snippetTransferFetch(db, id)
+	if err != nil {
+		return nil, &internalServerError{"Could not fetch transfer", err}
+	}
+
+	if t == nil {
+		return nil, &notFoundError{"No such transfer"}
+	}
+
+	return t, nil
+}
+
+func apiSnippetTransferAccept(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	t, apierr := apiFetchTransfer(db, req)
+	if apierr != nil {
+		return apierr
+	}
+
+	if t.To != req.Username {
+		return &forbiddenError{"This snippet is not being transferred to you"}
+	}
+
+	from, err := userFetch(db, t.From)
+	if err != nil {
+		return &internalServerError{"Could not fetch user", err}
+	}
+
+	fromName := t.From
+	if from != nil {
+		fromName = from.DisplayName
+	}
+
+	err = snippetTransferAccept(db, t)
+	if err != nil {
+		return &internalServerError{"Could not accept transfer", err}
+	}
+
+	// The handover is kept in the history of the snippet. The transfer
+	// has already happened by now, so failing to record it is only logged
+	err = repoNote(
+		t.SnippetID,
+		req.User,
+		fmt.Sprintf("Transfer ownership from %s to %s\n", fromName, req.User.DisplayName),
+	)
+	if err != nil {
+		errLog.Printf("Could not record transfer of %s: %s", t.SnippetID, err)
+	}
+
+	return nil
+}
+
+// apiSnippetTransferCancel withdraws a transfer, whether by the
+// user who asked for it or by the user who is declining it
+func apiSnippetTransferCancel(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	t, apierr := apiFetchTransfer(db, req)
+	if apierr != nil {
+		return apierr
+	}
+
+	if t.From != req.Username && t.To != req.Username && !config.IsAdmin(req.Username) {
+		return &forbiddenError{"You do not have permission to cancel this transfer"}
+	}
+
+	err := snippetTransferCancel(db, t.SnippetID)
+	if err != nil {
+		return &internalServerError{"Could not cancel transfer", err}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package summa
+
+import (
+	"database/sql"
+	_ "go-sqlite3"
+)
+
+func apiTrash(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	username := req.Username
+
+	// Administrators may look through everyone's trash
+	all, _ := req.Data["all"].(bool)
+	if all {
+		if !config.IsAdmin(req.Username) {
+			return &forbiddenError{"You do not have permission to view all deleted snippets"}
+		}
+		username = ""
+	}
+
+	snips, err := snippetsTrash(db, username)
+	if err != nil {
+		return &internalServerError{"Could not fetch deleted snippets", err}
+	}
+
+	resp["snippets"] = snips
+	resp["retention"] = config.TrashRetention
+
+	return nil
+}
+
+// apiFetchTrashed fetches a snippet from the trash for the owner of the
+// snippet or an administrator, as long as it is still within the
+// retention period
+func apiFetchTrashed(db *sql.DB, req apiRequest) (*snippet, apiError) {
+	id, ok := req.Data["id"].(string)
+
+	if !ok {
+		return nil, &badRequestError{"The 'id' field must be a string"}
+	}
+
+	snip, err := snippetFetchDeleted(db, id)
+	if err != nil {
+		return nil, &internalServerError{"Could not fetch snippet", err}
+	}
+
+	if snip == nil || snip.Deleted <= UnixMilliseconds()-config.TrashRetention {
+		return nil, &notFoundError{"No such deleted snippet"}
+	}
+
+	if snip.Username != req.Username && !config.IsAdmin(req.Username) {
+		return nil, &forbiddenError{"You do not have permission to change this snippet"}
+	}
+
+	return snip, nil
+}
+
+func apiSnippetRestore(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	snip, apierr := apiFetchTrashed(db, req)
+	if apierr != nil {
+		return apierr
+	}
+
+	err := snippetRestore(db, snip.ID)
+	if err != nil {
+		return &internalServerError{"Could not restore snippet", err}
+	}
+
+	return nil
+}
+
+func apiSnippetPurge(db *sql.DB, req apiRequest, resp apiResponseData) apiError {
+	snip, apierr := apiFetchTrashed(db, req)
+	if apierr != nil {
+		return apierr
+	}
+
+	err := snippetPurge(db, snip.ID)
+	if err != nil {
+		return &internalServerError{"Could not purge snippet", err}
+	}
+
+	return nil
+}
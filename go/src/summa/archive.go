@@ -0,0 +1,156 @@
+package summa
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	ARCHIVE_ZIP    = ".zip"
+	ARCHIVE_TAR_GZ = ".tar.gz"
+)
+
+// handleArchiveRequest serves all the files of a snippet bundled into a single
+// archive, as requested by a path of the form /archive/<id>.zip or
+// /archive/<id>.tar.gz. The files are bundled as they were at the revision
+// given by the optional rev parameter
+func handleArchiveRequest(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Server", "Summa/1.0.0")
+
+	if req.Method != "GET" && req.Method != "HEAD" {
+		http.Error(w, METHOD_NOT_ALLOWED, http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(req.URL.Path, "/archive/")
+
+	var format string
+	for _, ext := range []string{ARCHIVE_ZIP, ARCHIVE_TAR_GZ} {
+		if strings.HasSuffix(name, ext) {
+			format = ext
+		}
+	}
+
+	id := strings.TrimSuffix(name, format)
+	if format == "" || id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, req)
+		return
+	}
+
+	db := httpOpenAuthenticated(w, req)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+
+	exists, err := snippetExists(db, id)
+	if err != nil {
+		httpInternalError(w, "Could not check if snippet exists", err)
+		return
+	}
+
+	if !exists {
+		http.NotFound(w, req)
+		return
+	}
+
+	rev := req.URL.Query().Get("rev")
+
+	files, err := repoReadFiles(id, rev)
+	if err != nil {
+		httpInternalError(w, "Could not read snippet files", err)
+		return
+	}
+
+	if files == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+format))
+
+	switch format {
+	case ARCHIVE_ZIP:
+		header.Set("Content-Type", "application/zip")
+	case ARCHIVE_TAR_GZ:
+		header.Set("Content-Type", "application/gzip")
+	}
+
+	if req.Method == "HEAD" {
+		return
+	}
+
+	// Files are placed in a directory named after the snippet,
+	// so extracting an archive doesn't scatter them around
+	switch format {
+	case ARCHIVE_ZIP:
+		err = archiveZip(w, id, files)
+	case ARCHIVE_TAR_GZ:
+		err = archiveTarGz(w, id, files)
+	}
+
+	if err != nil {
+		errLog.Printf("Could not write archive for %s: %s", id, err)
+	}
+}
+
+// archiveZip writes the given files to a zip archive
+func archiveZip(w io.Writer, dir string, files snippetFiles) error {
+	zw := zip.NewWriter(w)
+
+	for _, file := range files {
+		fh := &zip.FileHeader{
+			Name:   dir + "/" + file.Filename,
+			Method: zip.Deflate,
+		}
+		fh.SetModTime(time.Now())
+
+		f, err := zw.CreateHeader(fh)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(f, file.Contents)
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// archiveTarGz writes the given files to a gzip compressed tar archive
+func archiveTarGz(w io.Writer, dir string, files snippetFiles) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for _, file := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    dir + "/" + file.Filename,
+			Mode:    0644,
+			Size:    int64(len(file.Contents)),
+			ModTime: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(tw, file.Contents)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
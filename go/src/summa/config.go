@@ -8,12 +8,18 @@ import (
 type AuthProvider func(username, password string) (*User, error)
 
 type Config struct {
-	Listen        string
-	SSLEnable     bool
-	SessionExpire int64
-	AuthProvider  AuthProvider
-	DirPaths      map[string]string
-	FilePaths     map[string]string
+	Listen            string
+	SSLEnable         bool
+	SessionExpire     int64
+	TrashRetention    int64
+	MaxFileSize       int64
+	MaxInlineFileSize int64
+	Admins            []string
+	EmbedPublic       bool
+	EmbedSecret       string
+	AuthProvider      AuthProvider
+	DirPaths          map[string]string
+	FilePaths         map[string]string
 }
 
 var config *Config
@@ -38,6 +44,17 @@ func (c *Config) SetAuthProvider(ap AuthProvider) {
 	c.AuthProvider = ap
 }
 
+// IsAdmin returns true if the given username is listed
+// as an administrator in the configuration file
+func (c *Config) IsAdmin(username string) bool {
+	for _, admin := range c.Admins {
+		if admin == username {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Config) WebRoot() string {
 	return c.DirPaths["WebRoot"]
 }
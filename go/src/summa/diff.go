@@ -0,0 +1,237 @@
+package summa
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	DIFF_CONTEXT = 3
+
+	// The largest number of line pairs compared when diffing, beyond
+	// which everything between the common prefix and suffix is
+	// treated as replaced
+	DIFF_CELLS_MAX = 4 << 20
+)
+
+const (
+	DIFF_EQUAL  = ' '
+	DIFF_DELETE = '-'
+	DIFF_INSERT = '+'
+)
+
+// diffEdit is a single line of an edit script turning one text into another
+type diffEdit struct {
+	Op   byte
+	Line string
+}
+
+type diffEdits []diffEdit
+
+// diffSplitLines splits text into lines, without their line endings
+func diffSplitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns the shortest edit script that turns the lines
+// of a into the lines of b
+func diffLines(a, b []string) diffEdits {
+	var edits diffEdits
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for _, line := range a[:prefix] {
+		edits = append(edits, diffEdit{DIFF_EQUAL, line})
+	}
+
+	edits = append(edits, diffLCS(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, diffEdit{DIFF_EQUAL, line})
+	}
+
+	return edits
+}
+
+// diffLCS builds an edit script from the longest common subsequence
+// of two lists of lines
+func diffLCS(a, b []string) diffEdits {
+	var edits diffEdits
+	n, m := len(a), len(b)
+
+	if n*m > DIFF_CELLS_MAX {
+		for _, line := range a {
+			edits = append(edits, diffEdit{DIFF_DELETE, line})
+		}
+		for _, line := range b {
+			edits = append(edits, diffEdit{DIFF_INSERT, line})
+		}
+		return edits
+	}
+
+	// lcs[i][j] is the length of the longest common
+	// subsequence of a[i:] and b[j:]
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			edits = append(edits, diffEdit{DIFF_EQUAL, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, diffEdit{DIFF_DELETE, a[i]})
+			i++
+		default:
+			edits = append(edits, diffEdit{DIFF_INSERT, b[j]})
+			j++
+		}
+	}
+
+	for ; i < n; i++ {
+		edits = append(edits, diffEdit{DIFF_DELETE, a[i]})
+	}
+	for ; j < m; j++ {
+		edits = append(edits, diffEdit{DIFF_INSERT, b[j]})
+	}
+
+	return edits
+}
+
+// diffSimilarity returns how alike two texts are as a percentage,
+// based on the number of lines they have in common
+func diffSimilarity(a, b string) int {
+	if a == b {
+		return 100
+	}
+
+	aLines, bLines := diffSplitLines(a), diffSplitLines(b)
+	total := len(aLines) + len(bLines)
+	if total == 0 {
+		return 100
+	}
+
+	common := 0
+	for _, edit := range diffLines(aLines, bLines) {
+		if edit.Op == DIFF_EQUAL {
+			common++
+		}
+	}
+
+	return 200 * common / total
+}
+
+// diffUnified returns the differences between two texts in unified diff format
+func diffUnified(aName, bName, a, b string) string {
+	var buf bytes.Buffer
+
+	edits := diffLines(diffSplitLines(a), diffSplitLines(b))
+
+	// aLine and bLine hold the line numbers, counting
+	// from zero, at the start of each edit
+	aLine := make([]int, len(edits)+1)
+	bLine := make([]int, len(edits)+1)
+	for i, edit := range edits {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if edit.Op != DIFF_INSERT {
+			aLine[i+1]++
+		}
+		if edit.Op != DIFF_DELETE {
+			bLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(edits); {
+		if edits[i].Op == DIFF_EQUAL {
+			i++
+			continue
+		}
+
+		// Grow the hunk until the gap to the next change
+		// is too wide to be bridged by context lines
+		start := i - DIFF_CONTEXT
+		if start < 0 {
+			start = 0
+		}
+
+		end := i
+		for end < len(edits) {
+			next := end
+			for next < len(edits) && edits[next].Op == DIFF_EQUAL {
+				next++
+			}
+			if next == len(edits) || next-end > 2*DIFF_CONTEXT {
+				break
+			}
+			for end = next; end < len(edits) && edits[end].Op != DIFF_EQUAL; end++ {
+			}
+		}
+
+		stop := end + DIFF_CONTEXT
+		if stop > len(edits) {
+			stop = len(edits)
+		}
+
+		if buf.Len() == 0 {
+			fmt.Fprintf(&buf, "--- %s\n+++ %s\n", aName, bName)
+		}
+
+		fmt.Fprintf(
+			&buf,
+			"@@ -%s +%s @@\n",
+			diffRange(aLine[start], aLine[stop]-aLine[start]),
+			diffRange(bLine[start], bLine[stop]-bLine[start]),
+		)
+
+		for _, edit := range edits[start:stop] {
+			buf.WriteByte(edit.Op)
+			buf.WriteString(edit.Line)
+			buf.WriteByte('\n')
+		}
+
+		i = stop
+	}
+
+	return buf.String()
+}
+
+// diffRange formats the start and length of one side of a hunk
+func diffRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
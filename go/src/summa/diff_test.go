@@ -0,0 +1,123 @@
+package summa
+
+import (
+	"testing"
+)
+
+func TestDiffUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "unchanged",
+			a:    "one\ntwo\n",
+			b:    "one\ntwo\n",
+			want: "",
+		},
+		{
+			name: "added file",
+			a:    "",
+			b:    "one\ntwo\n",
+			want: "--- a\n+++ b\n" +
+				"@@ -0,0 +1,2 @@\n" +
+				"+one\n" +
+				"+two\n",
+		},
+		{
+			name: "removed contents",
+			a:    "one\n",
+			b:    "",
+			want: "--- a\n+++ b\n" +
+				"@@ -1 +0,0 @@\n" +
+				"-one\n",
+		},
+		{
+			name: "changed line with context",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			b:    "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want: "--- a\n+++ b\n" +
+				"@@ -2,7 +2,7 @@\n" +
+				" 2\n" +
+				" 3\n" +
+				" 4\n" +
+				"-5\n" +
+				"+five\n" +
+				" 6\n" +
+				" 7\n" +
+				" 8\n",
+		},
+		{
+			name: "nearby changes share a hunk",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			b:    "one\n2\n3\n4\n5\n6\n7\neight\n9\n",
+			want: "--- a\n+++ b\n" +
+				"@@ -1,9 +1,9 @@\n" +
+				"-1\n" +
+				"+one\n" +
+				" 2\n" +
+				" 3\n" +
+				" 4\n" +
+				" 5\n" +
+				" 6\n" +
+				" 7\n" +
+				"-8\n" +
+				"+eight\n" +
+				" 9\n",
+		},
+		{
+			name: "distant changes get their own hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:    "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			want: "--- a\n+++ b\n" +
+				"@@ -1,4 +1,4 @@\n" +
+				"-1\n" +
+				"+one\n" +
+				" 2\n" +
+				" 3\n" +
+				" 4\n" +
+				"@@ -9,4 +9,4 @@\n" +
+				" 9\n" +
+				" 10\n" +
+				" 11\n" +
+				"-12\n" +
+				"+twelve\n",
+		},
+		{
+			name: "inserted lines",
+			a:    "one\nthree\n",
+			b:    "one\ntwo\nthree\n",
+			want: "--- a\n+++ b\n" +
+				"@@ -1,2 +1,3 @@\n" +
+				" one\n" +
+				"+two\n" +
+				" three\n",
+		},
+	}
+
+	for _, test := range tests {
+		got := diffUnified("a", "b", test.a, test.b)
+		if got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDiffSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 100},
+		{"one\ntwo\n", "one\ntwo\n", 100},
+		{"one\ntwo\n", "one\nthree\n", 50},
+		{"one\n", "two\n", 0},
+	}
+
+	for _, test := range tests {
+		if got := diffSimilarity(test.a, test.b); got != test.want {
+			t.Errorf("diffSimilarity(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package summa
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	EMBED_WIDTH_DEFAULT  = 640
+	EMBED_HEIGHT_DEFAULT = 400
+
+	// Where the embed secret is kept, next to the database,
+	// when none is set in the configuration file
+	EMBED_SECRET_FILE = "embed.secret"
+)
+
+// Snippets are found in the pages of the web interface, such as
+// /#/snippet/<id>, as well as in the embed URLs themselves
+var embedURLPattern = regexp.MustCompile(`/(?:snippet|embed)/([0-9a-z]+)`)
+
+var embedTemplate = template.Must(template.New("embed").Parse(`<style>
+.summa-embed { font: 13px/1.4 sans-serif; color: #333; margin: 0 0 1em; }
+.summa-embed-file { border: 1px solid #ddd; border-radius: 3px; margin-bottom: 0.5em; overflow: hidden; }
+.summa-embed-header { background: #f5f5f5; border-bottom: 1px solid #ddd; padding: 4px 8px; font-weight: bold; }
+.summa-embed-code { margin: 0; padding: 8px; overflow: auto; font: 12px/1.4 Consolas, monospace; background: #fff; }
+.summa-embed-markdown { padding: 0 8px; }
+.summa-embed-omitted { padding: 8px; color: #777; }
+.summa-embed-footer { color: #777; font-size: 12px; }
+.summa-embed a { color: #4078c0; text-decoration: none; }
+.summa-embed .hl-comment { color: #998; font-style: italic; }
+.summa-embed .hl-string { color: #d14; }
+.summa-embed .hl-key { color: #008080; }
+.summa-embed .hl-number { color: #099; }
+.summa-embed .hl-keyword { color: #333; font-weight: bold; }
+.summa-embed .hl-literal { color: #0086b3; }
+</style>
+<div class="summa-embed">
+{{range .Files}}<div class="summa-embed-file">
+<div class="summa-embed-header">{{.Filename}}</div>
+{{if .Omitted}}<div class="summa-embed-omitted">This file can't be shown here. <a href="{{$.URL}}" target="_blank">View it in Summa</a></div>
+{{else if .Markdown}}<div class="summa-embed-markdown">{{.HTML}}</div>
+{{else}}<pre class="summa-embed-code">{{.HTML}}</pre>
+{{end}}</div>
+{{end}}<div class="summa-embed-footer"><a href="{{.URL}}" target="_blank">{{.Description}}</a> by {{.DisplayName}}, hosted by Summa</div>
+</div>
+`))
+
+type embedFile struct {
+	Filename string
+	Markdown bool
+	Omitted  bool
+	HTML     template.HTML
+}
+
+// embedSignature returns the signature that allows a snippet to be embedded
+// without authenticating
+func embedSignature(id string) string {
+	mac := hmac.New(sha256.New, []byte(config.EmbedSecret))
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// embedSignatureIsValid returns true if sig is the embed signature of a snippet
+func embedSignatureIsValid(id, sig string) bool {
+	return hmac.Equal([]byte(sig), []byte(embedSignature(id)))
+}
+
+// embedSecretLoad returns the secret for signing embed links kept in the
+// file at the given path, generating and saving one if there isn't one yet.
+// This keeps links working when none is set in the configuration file and
+// the server is restarted
+func embedSecretLoad(filePath string) (string, error) {
+	b, err := ioutil.ReadFile(filePath)
+	switch {
+	case err == nil && len(bytes.TrimSpace(b)) > 0:
+		return string(bytes.TrimSpace(b)), nil
+	case err != nil && !os.IsNotExist(err):
+		return "", err
+	}
+
+	b = make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	secret := hex.EncodeToString(b)
+
+	err = ioutil.WriteFile(filePath, []byte(secret), 0600)
+	if err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// embedIsAllowed checks whether a snippet may be embedded, which it may if
+// embedding is public, the signature is valid or the request is authenticated
+func embedIsAllowed(db *sql.DB, req *http.Request, id, sig string) (bool, error) {
+	if config.EmbedPublic || embedSignatureIsValid(id, sig) {
+		return true, nil
+	}
+
+	return httpAuthenticate(db, req)
+}
+
+// embedPath returns the path of an embed of a snippet, signed
+// unless embedding is public
+func embedPath(id, ext string) string {
+	p := "/embed/" + id + ext
+	if !config.EmbedPublic {
+		p += "?sig=" + embedSignature(id)
+	}
+	return p
+}
+
+// embedBaseURL returns the scheme and host the request was made to
+func embedBaseURL(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + req.Host
+}
+
+// embedRender will write the HTML fragment showing the files of a snippet,
+// or only the file with the given filename if one is given
+func embedRender(snip *snippet, filename, snippetURL string) ([]byte, error) {
+	var files []embedFile
+
+	for _, file := range snip.Files {
+		if filename != "" && file.Filename != filename {
+			continue
+		}
+
+		f := embedFile{
+			Filename: file.Filename,
+			Markdown: file.Language == LANG_MARKDOWN,
+			Omitted:  file.Omitted,
+			HTML:     template.HTML(file.HTML),
+		}
+
+		if !f.Omitted && f.HTML == "" {
+			f.HTML = template.HTML(highlightFile(file.Language, file.Contents))
+		}
+
+		if !f.Omitted && f.HTML == "" {
+			f.HTML = template.HTML(template.HTMLEscapeString(file.Contents))
+		}
+
+		files = append(files, f)
+	}
+
+	var buf bytes.Buffer
+	err := embedTemplate.Execute(&buf, map[string]interface{}{
+		"Files":       files,
+		"URL":         snippetURL,
+		"Description": snip.Description,
+		"DisplayName": snip.DisplayName,
+	})
+
+	return buf.Bytes(), err
+}
+
+// handleEmbedRequest serves a snippet for inclusion in other pages, as
+// requested by a path of the form /embed/<id>.html for an HTML page to be
+// framed or /embed/<id>.js for a script that writes a frame showing that
+// page into the page including it. Unless embedding is public, the request must either be
+// authenticated or carry the snippet's embed signature in the sig parameter
+func handleEmbedRequest(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Server", "Summa/1.0.0")
+
+	if req.Method != "GET" && req.Method != "HEAD" {
+		http.Error(w, METHOD_NOT_ALLOWED, http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(req.URL.Path, "/embed/")
+
+	var id, ext string
+	for _, e := range []string{".html", ".js"} {
+		if strings.HasSuffix(name, e) {
+			id, ext = strings.TrimSuffix(name, e), e
+		}
+	}
+
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, req)
+		return
+	}
+
+	query := req.URL.Query()
+
+	db, err := sql.Open("sqlite3", config.DBFile())
+	if err != nil {
+		httpInternalError(w, "Could not open database", err)
+		return
+	}
+	defer db.Close()
+
+	allowed, err := embedIsAllowed(db, req, id, query.Get("sig"))
+	if err != nil {
+		httpInternalError(w, "Could not check for valid session", err)
+		return
+	}
+
+	if !allowed {
+		http.Error(w, "Invalid or missing embed signature", http.StatusUnauthorized)
+		return
+	}
+
+	snip, err := snippetFetch(db, id)
+	if err != nil {
+		httpInternalError(w, "Could not fetch snippet", err)
+		return
+	}
+
+	if snip == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	header := w.Header()
+
+	// Markdown files may hold raw HTML, so the script writes a frame
+	// showing the sandboxed page rather than the snippet itself
+	if ext == ".js" {
+		src := embedBaseURL(req) + embedPath(id, ".html")
+		if filename := query.Get("file"); filename != "" {
+			if strings.Contains(src, "?") {
+				src += "&"
+			} else {
+				src += "?"
+			}
+			src += "file=" + url.QueryEscape(filename)
+		}
+
+		quoted, err := json.Marshal(fmt.Sprintf(
+			`<iframe src="%s" width="%d" height="%d" frameborder="0"></iframe>`,
+			template.HTMLEscapeString(src),
+			EMBED_WIDTH_DEFAULT,
+			EMBED_HEIGHT_DEFAULT,
+		))
+		if err != nil {
+			httpInternalError(w, "Could not render snippet", err)
+			return
+		}
+
+		header.Set("Content-Type", "application/javascript; charset=utf-8")
+		fmt.Fprintf(w, "document.write(%s);\n", quoted)
+		return
+	}
+
+	fragment, err := embedRender(snip, query.Get("file"), embedBaseURL(req)+"/#/snippet/"+id)
+	if err != nil {
+		httpInternalError(w, "Could not render snippet", err)
+		return
+	}
+
+	// Descriptions and markdown files may hold raw HTML, which mustn't
+	// run as part of the app when the page is opened directly
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	header.Set("Content-Security-Policy", "sandbox allow-popups allow-popups-to-escape-sandbox")
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<base target=\"_blank\">\n")
+	fmt.Fprintf(w, "<title>%s</title>\n</head>\n<body>\n", template.HTMLEscapeString(snip.Description))
+	w.Write(fragment)
+	fmt.Fprintf(w, "</body>\n</html>\n")
+}
+
+// handleOEmbedRequest answers oEmbed discovery requests of the form
+// /oembed?url=<snippet url>, describing how to embed the snippet in a frame.
+// Only the JSON format is supported
+func handleOEmbedRequest(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Server", "Summa/1.0.0")
+
+	if req.Method != "GET" && req.Method != "HEAD" {
+		http.Error(w, METHOD_NOT_ALLOWED, http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := req.URL.Query()
+
+	if format := query.Get("format"); format != "" && format != "json" {
+		http.Error(w, "Only the json format is supported", http.StatusNotImplemented)
+		return
+	}
+
+	target, err := url.Parse(query.Get("url"))
+	if err != nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	match := embedURLPattern.FindStringSubmatch(target.Path + "/" + target.Fragment)
+	if match == nil {
+		http.NotFound(w, req)
+		return
+	}
+	id := match[1]
+
+	width := EMBED_WIDTH_DEFAULT
+	if maxWidth, err := strconv.Atoi(query.Get("maxwidth")); err == nil && maxWidth > 0 && maxWidth < width {
+		width = maxWidth
+	}
+
+	height := EMBED_HEIGHT_DEFAULT
+	if maxHeight, err := strconv.Atoi(query.Get("maxheight")); err == nil && maxHeight > 0 && maxHeight < height {
+		height = maxHeight
+	}
+
+	db, err := sql.Open("sqlite3", config.DBFile())
+	if err != nil {
+		httpInternalError(w, "Could not open database", err)
+		return
+	}
+	defer db.Close()
+
+	allowed, err := embedIsAllowed(db, req, id, target.Query().Get("sig"))
+	if err != nil {
+		httpInternalError(w, "Could not check for valid session", err)
+		return
+	}
+
+	if !allowed {
+		http.Error(w, "Invalid or missing embed signature", http.StatusUnauthorized)
+		return
+	}
+
+	snip, err := snippetFetch(db, id)
+	if err != nil {
+		httpInternalError(w, "Could not fetch snippet", err)
+		return
+	}
+
+	if snip == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	base := embedBaseURL(req)
+
+	b, err := json.Marshal(map[string]interface{}{
+		"version":       "1.0",
+		"type":          "rich",
+		"provider_name": "Summa",
+		"provider_url":  base + "/",
+		"title":         snip.Description,
+		"author_name":   snip.DisplayName,
+		"width":         width,
+		"height":        height,
+		"html": fmt.Sprintf(
+			`<iframe src="%s" width="%d" height="%d" frameborder="0"></iframe>`,
+			template.HTMLEscapeString(base+embedPath(id, ".html")),
+			width,
+			height,
+		),
+	})
+	if err != nil {
+		httpInternalError(w, "Could not encode oEmbed response", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(b)
+}
@@ -0,0 +1,63 @@
+package summa
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	FILE_SIZE_MAX_DEFAULT    = 1 << 20
+	FILE_SIZE_INLINE_DEFAULT = 256 << 10
+
+	// The amount of a file looked at to
+	// determine its type and encoding
+	FILE_SNIFF_LEN = 8000
+)
+
+// fileMimeType returns the mime type for a file given its name and the
+// start of its contents, preferring the type implied by its extension
+func fileMimeType(filename string, head []byte) string {
+	mimeType := mime.TypeByExtension(path.Ext(filename))
+	if mimeType != "" {
+		return mimeType
+	}
+
+	mimeType = http.DetectContentType(head)
+	if !IsBinary(head) && !strings.HasPrefix(mimeType, "text/") {
+		return "text/plain; charset=utf-8"
+	}
+
+	return mimeType
+}
+
+// fileSniff will fill in the size, mime type and binary flag of a snippet
+// file stored at the given path, returning the start of its contents
+func fileSniff(filePath string, file *snippetFile) ([]byte, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, FILE_SNIFF_LEN)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	file.Size = stat.Size()
+	file.Binary = IsBinary(head)
+	file.MimeType = fileMimeType(file.Filename, head)
+
+	return head, nil
+}
@@ -8,6 +8,7 @@ import "C"
 
 import (
 	"runtime"
+	"time"
 	"unsafe"
 )
 
@@ -100,7 +101,7 @@ func (r *GitRepository) Free() {
 	C.git_repository_free(r.ptr)
 }
 
-func (r *GitRepository) Commit(name, email string) error {
+func (r *GitRepository) Commit(name, email, message string) error {
 	var ret C.int
 
 	var index *C.git_index
@@ -140,7 +141,7 @@ func (r *GitRepository) Commit(name, email string) error {
 	ret = C.git_reference_name_to_id(headOid, r.ptr, cHead)
 
 	commitOid := new(C.git_oid)
-	cMessage := C.CString("")
+	cMessage := C.CString(message)
 	defer C.free(unsafe.Pointer(cMessage))
 
 	if ret == 0 {
@@ -251,3 +252,261 @@ func (i *GitIndex) Rm(file string) error {
 
 	return i.Write()
 }
+
+type GitTree struct {
+	ptr  *C.git_tree
+	repo *GitRepository
+}
+
+// RevparseTree looks up the tree of the commit named by a revision spec, such
+// as HEAD, HEAD~2 or a commit id. A nil tree is returned if there is no such
+// revision
+func (r *GitRepository) RevparseTree(spec string) (*GitTree, error) {
+	cSpec := C.CString(spec)
+	defer C.free(unsafe.Pointer(cSpec))
+
+	var obj *C.git_object
+	ret := C.git_revparse_single(&obj, r.ptr, cSpec)
+	if ret == C.GIT_ENOTFOUND || ret == C.GIT_EINVALIDSPEC || ret == C.GIT_EAMBIGUOUS {
+		return nil, nil
+	}
+	if ret < 0 {
+		return nil, GitErrorLast()
+	}
+	defer C.git_object_free(obj)
+
+	var peeled *C.git_object
+	ret = C.git_object_peel(&peeled, obj, C.GIT_OBJ_TREE)
+	if ret < 0 {
+		return nil, GitErrorLast()
+	}
+
+	tree := &GitTree{(*C.git_tree)(unsafe.Pointer(peeled)), r}
+	runtime.SetFinalizer(tree, (*GitTree).Free)
+	return tree, nil
+}
+
+func (r *GitRepository) blobContents(oid *C.git_oid) ([]byte, error) {
+	var blob *C.git_blob
+	ret := C.git_blob_lookup(&blob, r.ptr, oid)
+	if ret < 0 {
+		return nil, GitErrorLast()
+	}
+	defer C.git_blob_free(blob)
+
+	size := C.git_blob_rawsize(blob)
+	return C.GoBytes(C.git_blob_rawcontent(blob), C.int(size)), nil
+}
+
+func (t *GitTree) Free() {
+	runtime.SetFinalizer(t, nil)
+	C.git_tree_free(t.ptr)
+}
+
+// ReadFile returns the contents of the blob at the given path in the
+// tree, or nil if there is no such blob
+func (t *GitTree) ReadFile(path string) ([]byte, error) {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+
+	var entry *C.git_tree_entry
+	ret := C.git_tree_entry_bypath(&entry, t.ptr, cPath)
+	if ret == C.GIT_ENOTFOUND {
+		return nil, nil
+	}
+	if ret < 0 {
+		return nil, GitErrorLast()
+	}
+	defer C.git_tree_entry_free(entry)
+
+	if C.git_tree_entry_type(entry) != C.GIT_OBJ_BLOB {
+		return nil, nil
+	}
+
+	return t.repo.blobContents(C.git_tree_entry_id(entry))
+}
+
+// Files returns the paths of all the blobs in the tree and its subtrees
+func (t *GitTree) Files() ([]string, error) {
+	return t.files(t.ptr, "")
+}
+
+func (t *GitTree) files(tree *C.git_tree, prefix string) ([]string, error) {
+	var files []string
+
+	count := C.git_tree_entrycount(tree)
+	for i := C.size_t(0); i < count; i++ {
+		entry := C.git_tree_entry_byindex(tree, i)
+		name := prefix + C.GoString(C.git_tree_entry_name(entry))
+
+		switch C.git_tree_entry_type(entry) {
+		case C.GIT_OBJ_BLOB:
+			files = append(files, name)
+
+		case C.GIT_OBJ_TREE:
+			var subtree *C.git_tree
+			ret := C.git_tree_lookup(&subtree, t.repo.ptr, C.git_tree_entry_id(entry))
+			if ret < 0 {
+				return nil, GitErrorLast()
+			}
+
+			subFiles, err := t.files(subtree, name+"/")
+			C.git_tree_free(subtree)
+			if err != nil {
+				return nil, err
+			}
+
+			files = append(files, subFiles...)
+		}
+	}
+
+	return files, nil
+}
+
+type GitCommit struct {
+	ID          string
+	ParentID    string
+	AuthorName  string
+	AuthorEmail string
+	Time        time.Time
+}
+
+func gitOidString(oid *C.git_oid) string {
+	buf := make([]byte, 40)
+	C.git_oid_fmt((*C.char)(unsafe.Pointer(&buf[0])), oid)
+	return string(buf)
+}
+
+// Head returns the id of the commit HEAD points to, or an
+// empty string if there are no commits yet
+func (r *GitRepository) Head() (string, error) {
+	cHead := C.CString("HEAD")
+	defer C.free(unsafe.Pointer(cHead))
+
+	var oid C.git_oid
+	ret := C.git_reference_name_to_id(&oid, r.ptr, cHead)
+	if ret == C.GIT_ENOTFOUND {
+		return "", nil
+	}
+	if ret < 0 {
+		return "", GitErrorLast()
+	}
+
+	return gitOidString(&oid), nil
+}
+
+// Log returns the commits reachable from HEAD, newest first. No more than max
+// commits are returned if max is greater than zero
+func (r *GitRepository) Log(max int) ([]*GitCommit, error) {
+	var walk *C.git_revwalk
+	ret := C.git_revwalk_new(&walk, r.ptr)
+	if ret < 0 {
+		return nil, GitErrorLast()
+	}
+	defer C.git_revwalk_free(walk)
+
+	C.git_revwalk_sorting(walk, C.GIT_SORT_TIME)
+
+	ret = C.git_revwalk_push_head(walk)
+	if ret == C.GIT_ENOTFOUND {
+		return nil, nil
+	}
+	if ret < 0 {
+		return nil, GitErrorLast()
+	}
+
+	var commits []*GitCommit
+	var oid C.git_oid
+
+	for max <= 0 || len(commits) < max {
+		ret = C.git_revwalk_next(&oid, walk)
+		if ret == C.GIT_ITEROVER {
+			break
+		}
+		if ret < 0 {
+			return nil, GitErrorLast()
+		}
+
+		var commit *C.git_commit
+		ret = C.git_commit_lookup(&commit, r.ptr, &oid)
+		if ret < 0 {
+			return nil, GitErrorLast()
+		}
+
+		author := C.git_commit_author(commit)
+		c := &GitCommit{
+			ID:          gitOidString(&oid),
+			AuthorName:  C.GoString(author.name),
+			AuthorEmail: C.GoString(author.email),
+			Time:        time.Unix(int64(author.when.time), 0),
+		}
+
+		if C.git_commit_parentcount(commit) > 0 {
+			c.ParentID = gitOidString(C.git_commit_parent_id(commit, 0))
+		}
+
+		C.git_commit_free(commit)
+		commits = append(commits, c)
+	}
+
+	return commits, nil
+}
+
+// revparseID looks up the id of the object named by a revision spec, returning
+// false if there is no such revision
+func (r *GitRepository) revparseID(spec string, oid *C.git_oid) (bool, error) {
+	cSpec := C.CString(spec)
+	defer C.free(unsafe.Pointer(cSpec))
+
+	var obj *C.git_object
+	ret := C.git_revparse_single(&obj, r.ptr, cSpec)
+	if ret == C.GIT_ENOTFOUND || ret == C.GIT_EINVALIDSPEC || ret == C.GIT_EAMBIGUOUS {
+		return false, nil
+	}
+	if ret < 0 {
+		return false, GitErrorLast()
+	}
+	defer C.git_object_free(obj)
+
+	C.git_oid_cpy(oid, C.git_object_id(obj))
+	return true, nil
+}
+
+// MergeBase returns the id of the best common ancestor of two revisions, or
+// an empty string if either of them doesn't exist or they share no history
+func (r *GitRepository) MergeBase(one, two string) (string, error) {
+	var oneOid, twoOid, base C.git_oid
+
+	for _, rev := range []struct {
+		spec string
+		oid  *C.git_oid
+	}{{one, &oneOid}, {two, &twoOid}} {
+		found, err := r.revparseID(rev.spec, rev.oid)
+		if err != nil || !found {
+			return "", err
+		}
+	}
+
+	ret := C.git_merge_base(&base, r.ptr, &oneOid, &twoOid)
+	if ret == C.GIT_ENOTFOUND {
+		return "", nil
+	}
+	if ret < 0 {
+		return "", GitErrorLast()
+	}
+
+	return gitOidString(&base), nil
+}
+
+// Resolve returns the full id of the object named by a revision spec, or an
+// empty string if there is no such revision
+func (r *GitRepository) Resolve(spec string) (string, error) {
+	var oid C.git_oid
+
+	found, err := r.revparseID(spec, &oid)
+	if err != nil || !found {
+		return "", err
+	}
+
+	return gitOidString(&oid), nil
+}
@@ -0,0 +1,409 @@
+package summa
+
+import (
+	"bytes"
+	"html"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	HIGHLIGHT_CACHE_MAX = 1000
+)
+
+// highlightLang describes the lexical structure of a language,
+// just enough of it to color code for display
+type highlightLang struct {
+	Keywords        []string
+	Literals        []string
+	LineComments    []string
+	BlockComments   [][2]string
+	Quotes          string
+	RawQuotes       string
+	CaseInsensitive bool
+	Keys            bool
+
+	keywords map[string]bool
+	literals map[string]bool
+}
+
+type highlightCache struct {
+	sync.Mutex
+	entries map[string]string
+}
+
+var (
+	highlightLangs = map[string]*highlightLang{
+		"Go": &highlightLang{
+			Keywords: []string{
+				"break", "case", "chan", "const", "continue", "default", "defer", "else",
+				"fallthrough", "for", "func", "go", "goto", "if", "import", "interface",
+				"map", "package", "range", "return", "select", "struct", "switch", "type", "var",
+			},
+			Literals:      []string{"true", "false", "nil", "iota"},
+			LineComments:  []string{"//"},
+			BlockComments: [][2]string{{"/*", "*/"}},
+			Quotes:        "\"'",
+			RawQuotes:     "`",
+		},
+		"SQL": &highlightLang{
+			Keywords: []string{
+				"select", "from", "where", "and", "or", "not", "insert", "into", "values",
+				"update", "set", "delete", "create", "table", "index", "drop", "alter",
+				"join", "left", "right", "inner", "outer", "on", "using", "group", "by",
+				"order", "having", "limit", "offset", "as", "in", "is", "like", "distinct",
+				"union", "all", "primary", "key", "unique", "default", "replace", "begin",
+				"commit", "rollback", "case", "when", "then", "else", "end", "exists",
+			},
+			Literals:        []string{"null", "true", "false"},
+			LineComments:    []string{"--"},
+			BlockComments:   [][2]string{{"/*", "*/"}},
+			Quotes:          "'\"",
+			CaseInsensitive: true,
+		},
+		"JavaScript": &highlightLang{
+			Keywords: []string{
+				"break", "case", "catch", "class", "const", "continue", "debugger", "default",
+				"delete", "do", "else", "export", "extends", "finally", "for", "function",
+				"if", "import", "in", "instanceof", "let", "new", "return", "super", "switch",
+				"this", "throw", "try", "typeof", "var", "void", "while", "with", "yield",
+			},
+			Literals:      []string{"true", "false", "null", "undefined", "NaN"},
+			LineComments:  []string{"//"},
+			BlockComments: [][2]string{{"/*", "*/"}},
+			Quotes:        "\"'",
+			RawQuotes:     "`",
+		},
+		"Python": &highlightLang{
+			Keywords: []string{
+				"and", "as", "assert", "break", "class", "continue", "def", "del", "elif",
+				"else", "except", "exec", "finally", "for", "from", "global", "if", "import",
+				"in", "is", "lambda", "not", "or", "pass", "print", "raise", "return", "try",
+				"while", "with", "yield",
+			},
+			Literals:     []string{"True", "False", "None"},
+			LineComments: []string{"#"},
+			Quotes:       "\"'",
+		},
+		"Shell": &highlightLang{
+			Keywords: []string{
+				"if", "then", "else", "elif", "fi", "case", "esac", "for", "while", "until",
+				"do", "done", "in", "function", "return", "local", "export", "readonly",
+				"echo", "exit", "set", "unset", "shift", "source",
+			},
+			Literals:     []string{"true", "false"},
+			LineComments: []string{"#"},
+			Quotes:       "\"'",
+		},
+		"JSON": &highlightLang{
+			Literals: []string{"true", "false", "null"},
+			Quotes:   "\"",
+			Keys:     true,
+		},
+		"YAML": &highlightLang{
+			Literals:     []string{"true", "false", "null", "yes", "no", "on", "off"},
+			LineComments: []string{"#"},
+			Quotes:       "\"'",
+			Keys:         true,
+		},
+		"C": &highlightLang{
+			Keywords: []string{
+				"auto", "break", "case", "char", "const", "continue", "default", "do",
+				"double", "else", "enum", "extern", "float", "for", "goto", "if", "int",
+				"long", "register", "return", "short", "signed", "sizeof", "static",
+				"struct", "switch", "typedef", "union", "unsigned", "void", "volatile", "while",
+			},
+			Literals:      []string{"NULL"},
+			LineComments:  []string{"//"},
+			BlockComments: [][2]string{{"/*", "*/"}},
+			Quotes:        "\"'",
+		},
+		"Java": &highlightLang{
+			Keywords: []string{
+				"abstract", "boolean", "break", "byte", "case", "catch", "char", "class",
+				"continue", "default", "do", "double", "else", "enum", "extends", "final",
+				"finally", "float", "for", "if", "implements", "import", "instanceof", "int",
+				"interface", "long", "new", "package", "private", "protected", "public",
+				"return", "short", "static", "super", "switch", "synchronized", "this",
+				"throw", "throws", "try", "void", "volatile", "while",
+			},
+			Literals:      []string{"true", "false", "null"},
+			LineComments:  []string{"//"},
+			BlockComments: [][2]string{{"/*", "*/"}},
+			Quotes:        "\"'",
+		},
+		"Ruby": &highlightLang{
+			Keywords: []string{
+				"alias", "and", "begin", "break", "case", "class", "def", "defined", "do",
+				"else", "elsif", "end", "ensure", "for", "if", "in", "module", "next", "not",
+				"or", "redo", "rescue", "retry", "return", "self", "super", "then", "undef",
+				"unless", "until", "when", "while", "yield", "require",
+			},
+			Literals:     []string{"true", "false", "nil"},
+			LineComments: []string{"#"},
+			Quotes:       "\"'",
+		},
+		"PHP": &highlightLang{
+			Keywords: []string{
+				"abstract", "array", "as", "break", "case", "catch", "class", "const",
+				"continue", "default", "do", "echo", "else", "elseif", "extends", "final",
+				"for", "foreach", "function", "global", "if", "implements", "include",
+				"interface", "new", "private", "protected", "public", "require", "return",
+				"static", "switch", "throw", "try", "use", "var", "while",
+			},
+			Literals:        []string{"true", "false", "null"},
+			LineComments:    []string{"//", "#"},
+			BlockComments:   [][2]string{{"/*", "*/"}},
+			Quotes:          "\"'",
+			CaseInsensitive: true,
+		},
+		"CSS": &highlightLang{
+			BlockComments: [][2]string{{"/*", "*/"}},
+			Quotes:        "\"'",
+			Keys:          true,
+		},
+		"HTML": &highlightLang{
+			BlockComments: [][2]string{{"<!--", "-->"}},
+			Quotes:        "\"'",
+		},
+		"XML": &highlightLang{
+			BlockComments: [][2]string{{"<!--", "-->"}},
+			Quotes:        "\"'",
+		},
+		"INI": &highlightLang{
+			LineComments: []string{";", "#"},
+			Quotes:       "\"",
+			Keys:         true,
+		},
+		"Makefile": &highlightLang{
+			LineComments: []string{"#"},
+			Quotes:       "\"'",
+			Keys:         true,
+		},
+	}
+
+	highlightAliases = map[string]string{
+		"C++":        "C",
+		"C#":         "Java",
+		"TypeScript": "JavaScript",
+		"Batchfile":  "Shell",
+		"Tcsh":       "Shell",
+		"fish":       "Shell",
+		"Less":       "CSS",
+		"SCSS":       "CSS",
+		"TOML":       "INI",
+	}
+
+	highlightCached = &highlightCache{entries: make(map[string]string)}
+)
+
+func init() {
+	for _, lang := range highlightLangs {
+		lang.keywords = make(map[string]bool)
+		for _, kw := range lang.Keywords {
+			lang.keywords[kw] = true
+		}
+
+		lang.literals = make(map[string]bool)
+		for _, lit := range lang.Literals {
+			if lang.CaseInsensitive {
+				lit = strings.ToLower(lit)
+			}
+			lang.literals[lit] = true
+		}
+	}
+}
+
+// highlightLangFor returns the lexical rules for a language name,
+// or nil if the language can't be highlighted
+func highlightLangFor(language string) *highlightLang {
+	if alias, ok := highlightAliases[language]; ok {
+		language = alias
+	}
+	return highlightLangs[language]
+}
+
+// highlightCanHighlight returns true if there are
+// lexical rules for the given language
+func highlightCanHighlight(language string) bool {
+	return highlightLangFor(language) != nil
+}
+
+// highlightFile returns the HTML for a file of a given language, reusing
+// the HTML generated for an identical blob whenever possible
+func highlightFile(language, contents string) string {
+	if !highlightCanHighlight(language) {
+		return ""
+	}
+
+	key := language + "\x00" + repoBlobID([]byte(contents))
+
+	highlightCached.Lock()
+	out, ok := highlightCached.entries[key]
+	highlightCached.Unlock()
+
+	if ok {
+		return out
+	}
+
+	out = highlight(language, contents)
+
+	highlightCached.Lock()
+	if len(highlightCached.entries) >= HIGHLIGHT_CACHE_MAX {
+		highlightCached.entries = make(map[string]string)
+	}
+	highlightCached.entries[key] = out
+	highlightCached.Unlock()
+
+	return out
+}
+
+// highlight will take source code in a given language and return HTML
+// with each token wrapped in a span with a class describing its type
+func highlight(language, contents string) string {
+	var b bytes.Buffer
+
+	lang := highlightLangFor(language)
+	if lang == nil {
+		return ""
+	}
+
+	span := func(class, text string) {
+		b.WriteString(`<span class="hl-` + class + `">`)
+		b.WriteString(html.EscapeString(text))
+		b.WriteString("</span>")
+	}
+
+	lineStart := true
+	i := 0
+
+Tokens:
+	for i < len(contents) {
+		rest := contents[i:]
+		c := contents[i]
+
+		for _, lc := range lang.LineComments {
+			if strings.HasPrefix(rest, lc) {
+				end := strings.IndexByte(rest, '\n')
+				if end < 0 {
+					end = len(rest)
+				}
+				span("comment", rest[:end])
+				i += end
+				continue Tokens
+			}
+		}
+
+		for _, bc := range lang.BlockComments {
+			if strings.HasPrefix(rest, bc[0]) {
+				end := strings.Index(rest[len(bc[0]):], bc[1])
+				if end < 0 {
+					end = len(rest)
+				} else {
+					end += len(bc[0]) + len(bc[1])
+				}
+				span("comment", rest[:end])
+				i += end
+				lineStart = false
+				continue Tokens
+			}
+		}
+
+		switch {
+		case strings.IndexByte(lang.Quotes, c) >= 0 || strings.IndexByte(lang.RawQuotes, c) >= 0:
+			raw := strings.IndexByte(lang.RawQuotes, c) >= 0
+			end := 1
+			for end < len(rest) && rest[end] != c {
+				if !raw && rest[end] == '\n' {
+					break
+				}
+				if !raw && rest[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end < len(rest) && rest[end] == c {
+				end++
+			}
+			if end > len(rest) {
+				end = len(rest)
+			}
+
+			class := "string"
+			if lang.Keys && highlightIsKey(rest[end:]) {
+				class = "key"
+			}
+			span(class, rest[:end])
+			i += end
+
+		case c >= '0' && c <= '9':
+			end := 1
+			for end < len(rest) && highlightIsNumberByte(rest[end]) {
+				end++
+			}
+			span("number", rest[:end])
+			i += end
+
+		case highlightIsIdentByte(c):
+			end := 1
+			for end < len(rest) && (highlightIsIdentByte(rest[end]) || (rest[end] >= '0' && rest[end] <= '9')) {
+				end++
+			}
+
+			word := rest[:end]
+			lookup := word
+			if lang.CaseInsensitive {
+				lookup = strings.ToLower(word)
+			}
+
+			switch {
+			case lang.Keys && lineStart && highlightIsKey(rest[end:]):
+				span("key", word)
+			case lang.keywords[lookup]:
+				span("keyword", word)
+			case lang.literals[lookup]:
+				span("literal", word)
+			default:
+				b.WriteString(html.EscapeString(word))
+			}
+			i += end
+
+		default:
+			// Characters outside of tokens are written whole, as one or
+			// more bytes of UTF-8
+			_, size := utf8.DecodeRuneInString(rest)
+			b.WriteString(html.EscapeString(rest[:size]))
+			i += size
+
+			switch c {
+			case '\n':
+				lineStart = true
+			case ' ', '\t', '-':
+			default:
+				lineStart = false
+			}
+			continue Tokens
+		}
+
+		lineStart = false
+	}
+
+	return b.String()
+}
+
+// highlightIsKey returns true if the text following a token
+// shows that the token is the key of a key/value pair
+func highlightIsKey(s string) bool {
+	s = strings.TrimLeft(s, " \t")
+	return strings.HasPrefix(s, ":") || strings.HasPrefix(s, "=")
+}
+
+func highlightIsIdentByte(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func highlightIsNumberByte(c byte) bool {
+	return c == '.' || c == '_' || (c >= '0' && c <= '9') ||
+		(c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') || c == 'x' || c == 'X'
+}
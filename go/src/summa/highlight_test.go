@@ -0,0 +1,45 @@
+package summa
+
+import (
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		contents string
+		want     string
+	}{
+		{
+			name:     "non-ASCII outside tokens",
+			language: "YAML",
+			contents: "name: José → ok\n",
+			want:     `<span class="hl-key">name</span>: José → ok` + "\n",
+		},
+		{
+			name:     "non-ASCII next to an identifier",
+			language: "Go",
+			contents: "x := π\n",
+			want:     "x := π\n",
+		},
+		{
+			name:     "escaped outside tokens",
+			language: "Go",
+			contents: "a < b && c > d\n",
+			want:     "a &lt; b &amp;&amp; c &gt; d\n",
+		},
+		{
+			name:     "non-ASCII in a string",
+			language: "Go",
+			contents: `s := "naïve"` + "\n",
+			want:     `s := <span class="hl-string">&#34;naïve&#34;</span>` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		if got := highlight(test.language, test.contents); got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
@@ -1,11 +1,18 @@
 package summa
 
 import (
+	"database/sql"
+	_ "go-sqlite3"
 	"net/http"
+	"strings"
 )
 
 func StartHttp() {
 	http.HandleFunc("/api/", handleApiRequest)
+	http.HandleFunc("/raw/", handleRawRequest)
+	http.HandleFunc("/archive/", handleArchiveRequest)
+	http.HandleFunc("/embed/", handleEmbedRequest)
+	http.HandleFunc("/oembed", handleOEmbedRequest)
 	http.Handle("/", http.FileServer(http.Dir(config.WebRoot())))
 
 	if config.SSLEnable {
@@ -19,3 +26,56 @@ func StartHttp() {
 		http.ListenAndServe(config.Listen, nil)
 	}
 }
+
+// httpAuthenticate checks for a valid API token given in the Authorization
+// header, or a valid session given by the username and token in the request
+// headers. Neither is accepted in the query string, where it would end up
+// in logs, browser history and referrers
+func httpAuthenticate(db *sql.DB, req *http.Request) (bool, error) {
+	auth := req.Header.Get("Authorization")
+	if strings.HasPrefix(auth, "token ") {
+		username, err := userTokenUsername(db, strings.TrimSpace(auth[6:]))
+		return username != "", err
+	}
+
+	username := req.Header.Get("X-Summa-Username")
+	token := req.Header.Get("X-Summa-Token")
+
+	if username == "" || token == "" {
+		return false, nil
+	}
+
+	return sessionIsValid(db, username, token)
+}
+
+// httpOpenAuthenticated opens the database for a request that must be
+// authenticated. If the database can't be opened or the request isn't
+// authenticated, an error response is written and nil is returned
+func httpOpenAuthenticated(w http.ResponseWriter, req *http.Request) *sql.DB {
+	db, err := sql.Open("sqlite3", config.DBFile())
+	if err != nil {
+		httpInternalError(w, "Could not open database", err)
+		return nil
+	}
+
+	authenticated, err := httpAuthenticate(db, req)
+	if err != nil {
+		db.Close()
+		httpInternalError(w, "Could not check for valid session", err)
+		return nil
+	}
+
+	if !authenticated {
+		db.Close()
+		http.Error(w, "Invalid or expired authentication session", http.StatusUnauthorized)
+		return nil
+	}
+
+	return db
+}
+
+// httpInternalError logs an error and responds with a generic message
+func httpInternalError(w http.ResponseWriter, s string, err error) {
+	errLog.Printf("%s: %s", s, err)
+	http.Error(w, INTERNAL_ERROR, http.StatusInternalServerError)
+}
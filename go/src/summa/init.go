@@ -24,6 +24,18 @@ func Init(configFile string) error {
 
 	configFileDir := filepath.Dir(configFilePath)
 
+	if config.MaxFileSize <= 0 {
+		config.MaxFileSize = FILE_SIZE_MAX_DEFAULT
+	}
+
+	if config.MaxInlineFileSize <= 0 {
+		config.MaxInlineFileSize = FILE_SIZE_INLINE_DEFAULT
+	}
+
+	if config.TrashRetention <= 0 {
+		config.TrashRetention = TRASH_RETENTION_DEFAULT
+	}
+
 	// Resolve all directory path config settings
 	// into absolute paths, making sure that the
 	// directory exists
@@ -52,6 +64,14 @@ func Init(configFile string) error {
 		}
 	}
 
+	if config.EmbedSecret == "" {
+		secretFile := filepath.Join(filepath.Dir(config.DBFile()), EMBED_SECRET_FILE)
+		config.EmbedSecret, err = embedSecretLoad(secretFile)
+		if err != nil {
+			log.Fatalf("Could not load embed secret: %s", err)
+		}
+	}
+
 	err = startLogging(config.LogFile())
 	if err != nil {
 		log.Fatalf("Could not setup log file: %s", err)
@@ -60,5 +80,11 @@ func Init(configFile string) error {
 	infoLog.Printf("summa.Init()")
 	infoLog.Printf("Loaded configuration from %s", configFilePath)
 
+	// Databases created by earlier versions are missing newer columns
+	err = upgradeDatabase()
+	if err != nil {
+		log.Fatalf("Could not upgrade database: %s", err)
+	}
+
 	return nil
 }
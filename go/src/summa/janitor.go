@@ -0,0 +1,69 @@
+package summa
+
+import (
+	"database/sql"
+	_ "go-sqlite3"
+	"time"
+)
+
+const (
+	JANITOR_INTERVAL = time.Minute
+
+	TRASH_RETENTION_DEFAULT = 30 * 24 * 60 * 60 * 1000
+)
+
+// StartJanitor starts the background task that periodically moves
+// snippets that have expired to the trash, and purges those that
+// have been in the trash for longer than the retention period
+func StartJanitor() {
+	go func() {
+		for {
+			janitorRun()
+			time.Sleep(JANITOR_INTERVAL)
+		}
+	}()
+}
+
+// janitorRun will perform a single pass of the janitor, logging
+// rather than returning any errors as there is no one to return
+// them to
+func janitorRun() {
+	db, err := sql.Open("sqlite3", config.DBFile())
+	if err != nil {
+		errLog.Printf("Janitor could not open database: %s", err)
+		return
+	}
+	defer db.Close()
+
+	ids, err := snippetsExpired(db, UnixMilliseconds())
+	if err != nil {
+		errLog.Printf("Janitor could not fetch expired snippets: %s", err)
+		return
+	}
+
+	for _, id := range ids {
+		err = snippetDelete(db, id)
+		if err != nil {
+			errLog.Printf("Janitor could not delete expired snippet %s: %s", id, err)
+			continue
+		}
+
+		infoLog.Printf("Janitor deleted expired snippet %s", id)
+	}
+
+	ids, err = snippetsPurgeable(db, UnixMilliseconds()-config.TrashRetention)
+	if err != nil {
+		errLog.Printf("Janitor could not fetch snippets to purge: %s", err)
+		return
+	}
+
+	for _, id := range ids {
+		err = snippetPurge(db, id)
+		if err != nil {
+			errLog.Printf("Janitor could not purge snippet %s: %s", id, err)
+			continue
+		}
+
+		infoLog.Printf("Janitor purged snippet %s", id)
+	}
+}
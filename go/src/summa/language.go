@@ -0,0 +1,359 @@
+package summa
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	LANG_TEXT = "Text"
+)
+
+// language describes one entry of the canonical language registry
+type language struct {
+	Name         string   `json:"name"`
+	Aliases      []string `json:"aliases,omitempty"`
+	Extensions   []string `json:"extensions,omitempty"`
+	Filenames    []string `json:"filenames,omitempty"`
+	Interpreters []string `json:"interpreters,omitempty"`
+	Fences       []string `json:"fences"`
+}
+
+type languageList []language
+
+type languageCount struct {
+	Language string `json:"language"`
+	Count    int64  `json:"count"`
+}
+
+type languageCounts []languageCount
+
+var (
+	// The canonical language registry, which is kept in
+	// sync with the languages offered by the web client
+	languages = languageList{
+		{Name: "ABAP", Extensions: []string{"abap"}},
+		{Name: "ActionScript", Extensions: []string{"as"}},
+		{Name: "Ada", Extensions: []string{"adb", "ads"}},
+		{Name: "ApacheConf", Aliases: []string{"apache"}, Filenames: []string{".htaccess", "httpd.conf"}},
+		{Name: "Apex", Extensions: []string{"cls"}},
+		{Name: "AppleScript", Extensions: []string{"applescript", "scpt"}, Interpreters: []string{"osascript"}},
+		{Name: "Arc", Extensions: []string{"arc"}},
+		{Name: "Arduino", Extensions: []string{"ino"}},
+		{Name: "ASP", Extensions: []string{"asp", "asax", "ascx", "ashx", "asmx", "aspx"}},
+		{Name: "Assembly", Aliases: []string{"asm", "nasm"}, Extensions: []string{"asm", "nasm"}},
+		{Name: "Augeas", Extensions: []string{"aug"}},
+		{Name: "AutoHotkey", Aliases: []string{"ahk"}, Extensions: []string{"ahk"}},
+		{Name: "Awk", Extensions: []string{"awk"}, Interpreters: []string{"awk", "gawk", "mawk", "nawk"}},
+		{Name: "Batchfile", Aliases: []string{"bat", "batch", "dosbatch"}, Extensions: []string{"bat", "cmd"}},
+		{Name: "Befunge", Extensions: []string{"befunge"}},
+		{Name: "BlitzMax", Extensions: []string{"bmx"}},
+		{Name: "Boo", Extensions: []string{"boo"}},
+		{Name: "Brainfuck", Extensions: []string{"b", "bf"}},
+		{Name: "Bro", Extensions: []string{"bro"}},
+		{Name: "C", Extensions: []string{"c", "h"}},
+		{Name: "C-ObjDump", Extensions: []string{"c-objdump"}},
+		{Name: "C#", Aliases: []string{"csharp"}, Extensions: []string{"cs"}},
+		{Name: "C++", Aliases: []string{"cpp"}, Extensions: []string{"cc", "cpp", "cxx", "hpp", "hxx", "hh", "c++"}},
+		{Name: "C2hs Haskell", Aliases: []string{"c2hs"}, Extensions: []string{"chs"}},
+		{Name: "Ceylon", Extensions: []string{"ceylon"}},
+		{Name: "ChucK", Extensions: []string{"ck"}},
+		{Name: "CLIPS", Extensions: []string{"clp"}},
+		{Name: "Clojure", Aliases: []string{"clj"}, Extensions: []string{"clj", "cljs", "cljc"}},
+		{Name: "CMake", Extensions: []string{"cmake"}, Filenames: []string{"CMakeLists.txt"}},
+		{Name: "CoffeeScript", Aliases: []string{"coffee"}, Extensions: []string{"coffee"}, Filenames: []string{"Cakefile"}, Interpreters: []string{"coffee"}},
+		{Name: "ColdFusion", Aliases: []string{"cfm"}, Extensions: []string{"cfm", "cfc"}},
+		{Name: "Common Lisp", Aliases: []string{"lisp"}, Extensions: []string{"lisp", "lsp", "cl"}, Interpreters: []string{"sbcl", "clisp"}},
+		{Name: "Coq", Extensions: []string{"v"}},
+		{Name: "Cpp-ObjDump", Extensions: []string{"cppobjdump", "c++objdump", "cxx-objdump"}},
+		{Name: "CSS", Extensions: []string{"css"}},
+		{Name: "Cucumber", Aliases: []string{"gherkin"}, Extensions: []string{"feature"}},
+		{Name: "Cython", Aliases: []string{"pyrex"}, Extensions: []string{"pyx", "pxd"}},
+		{Name: "D", Extensions: []string{"d", "di"}},
+		{Name: "D-ObjDump", Extensions: []string{"d-objdump"}},
+		{Name: "Darcs Patch", Aliases: []string{"dpatch"}, Extensions: []string{"darcspatch", "dpatch"}},
+		{Name: "Dart", Extensions: []string{"dart"}, Interpreters: []string{"dart"}},
+		{Name: "DCPU-16 ASM", Aliases: []string{"dasm16"}, Extensions: []string{"dasm16", "dasm"}},
+		{Name: "Delphi", Aliases: []string{"pascal"}, Extensions: []string{"pas", "dpr"}},
+		{Name: "Diff", Aliases: []string{"patch"}, Extensions: []string{"diff", "patch"}},
+		{Name: "DOT", Aliases: []string{"graphviz"}, Extensions: []string{"dot", "gv"}},
+		{Name: "Dylan", Extensions: []string{"dylan"}},
+		{Name: "eC", Extensions: []string{"ec", "eh"}},
+		{Name: "Ecere Projects", Extensions: []string{"epj"}},
+		{Name: "Ecl", Extensions: []string{"ecl"}},
+		{Name: "edn", Extensions: []string{"edn"}},
+		{Name: "Eiffel", Extensions: []string{"e"}},
+		{Name: "Elixir", Extensions: []string{"ex", "exs"}, Interpreters: []string{"elixir"}},
+		{Name: "Elm", Extensions: []string{"elm"}},
+		{Name: "Emacs Lisp", Aliases: []string{"elisp", "emacs"}, Extensions: []string{"el"}, Filenames: []string{".emacs"}},
+		{Name: "Erlang", Extensions: []string{"erl", "hrl"}, Interpreters: []string{"escript"}},
+		{Name: "F#", Aliases: []string{"fsharp"}, Extensions: []string{"fs", "fsi", "fsx"}},
+		{Name: "Factor", Extensions: []string{"factor"}},
+		{Name: "Fancy", Extensions: []string{"fy", "fancypack"}},
+		{Name: "Fantom", Extensions: []string{"fan"}},
+		{Name: "fish", Extensions: []string{"fish"}, Interpreters: []string{"fish"}},
+		{Name: "Forth", Extensions: []string{"fth", "4th"}},
+		{Name: "FORTRAN", Aliases: []string{"fortran"}, Extensions: []string{"f", "f90", "f95", "for"}},
+		{Name: "GAS", Extensions: []string{"s"}},
+		{Name: "Genshi", Aliases: []string{"xml+genshi", "xml+kid"}, Extensions: []string{"kid"}},
+		{Name: "Gentoo Ebuild", Extensions: []string{"ebuild"}},
+		{Name: "Gentoo Eclass", Extensions: []string{"eclass"}},
+		{Name: "Gettext Catalog", Aliases: []string{"pot", "po"}, Extensions: []string{"po", "pot"}},
+		{Name: "Go", Aliases: []string{"golang"}, Extensions: []string{"go"}},
+		{Name: "Gosu", Extensions: []string{"gs"}},
+		{Name: "Groff", Extensions: []string{"man", "roff", "1", "2", "3", "4", "5", "6", "7"}},
+		{Name: "Groovy", Extensions: []string{"groovy", "gradle"}, Interpreters: []string{"groovy"}},
+		{Name: "Groovy Server Pages", Extensions: []string{"gsp"}},
+		{Name: "Haml", Extensions: []string{"haml"}},
+		{Name: "Handlebars", Extensions: []string{"handlebars", "hbs"}},
+		{Name: "Haskell", Extensions: []string{"hs"}, Interpreters: []string{"runhaskell"}},
+		{Name: "Haxe", Extensions: []string{"hx", "hxsl"}},
+		{Name: "HTML", Aliases: []string{"xhtml"}, Extensions: []string{"htm", "html", "xhtml"}},
+		{Name: "HTML+Django", Aliases: []string{"django", "jinja"}, Extensions: []string{"mustache", "jinja"}},
+		{Name: "HTML+ERB", Aliases: []string{"erb"}, Extensions: []string{"erb"}},
+		{Name: "HTML+PHP", Extensions: []string{"phtml"}},
+		{Name: "HTTP"},
+		{Name: "INI", Aliases: []string{"dosini"}, Extensions: []string{"ini", "cfg", "prefs", "properties"}, Filenames: []string{".gitconfig", ".editorconfig"}},
+		{Name: "Io", Extensions: []string{"io"}, Interpreters: []string{"io"}},
+		{Name: "Ioke", Extensions: []string{"ik"}, Interpreters: []string{"ioke"}},
+		{Name: "IRC log", Aliases: []string{"irc"}, Extensions: []string{"irclog", "weechatlog"}},
+		{Name: "Java", Extensions: []string{"java"}},
+		{Name: "Java Server Pages", Aliases: []string{"jsp"}, Extensions: []string{"jsp"}},
+		{Name: "JavaScript", Aliases: []string{"js", "node"}, Extensions: []string{"js", "jsm", "mjs", "jsx"}, Interpreters: []string{"node", "nodejs", "rhino"}},
+		{Name: "JSON", Extensions: []string{"json", "geojson", "jsonld"}, Filenames: []string{".jshintrc", "composer.lock"}},
+		{Name: "Julia", Extensions: []string{"jl"}, Interpreters: []string{"julia"}},
+		{Name: "Kotlin", Extensions: []string{"kt", "kts"}},
+		{Name: "Lasso", Extensions: []string{"lasso"}},
+		{Name: "Less", Extensions: []string{"less"}},
+		{Name: "LilyPond", Extensions: []string{"ly", "ily"}},
+		{Name: "Literate CoffeeScript", Aliases: []string{"litcoffee"}, Extensions: []string{"litcoffee"}},
+		{Name: "Literate Haskell", Aliases: []string{"lhs"}, Extensions: []string{"lhs"}},
+		{Name: "LiveScript", Aliases: []string{"live-script", "ls"}, Extensions: []string{"ls"}, Filenames: []string{"Slakefile"}},
+		{Name: "LLVM", Extensions: []string{"ll"}},
+		{Name: "Logos", Extensions: []string{"xm", "x", "xi"}},
+		{Name: "Logtalk", Extensions: []string{"lgt"}},
+		{Name: "Lua", Extensions: []string{"lua"}, Interpreters: []string{"lua"}},
+		{Name: "Makefile", Aliases: []string{"make", "bsdmake", "mf"}, Extensions: []string{"mak", "mk"}, Filenames: []string{"Makefile", "GNUmakefile", "makefile"}, Interpreters: []string{"make"}},
+		{Name: "Mako", Extensions: []string{"mako", "mao"}},
+		{Name: "Markdown", Aliases: []string{"md"}, Extensions: []string{"md", "markdown", "mkd", "mkdn", "mdown"}},
+		{Name: "Matlab", Extensions: []string{"matlab", "m"}},
+		{Name: "Max", Aliases: []string{"max/msp", "maxmsp"}, Extensions: []string{"maxpat", "mxt"}},
+		{Name: "MiniD", Extensions: []string{"minid"}},
+		{Name: "Mirah", Extensions: []string{"druby", "duby", "mir", "mirah"}},
+		{Name: "Monkey", Extensions: []string{"monkey"}},
+		{Name: "Moocode", Extensions: []string{"moo"}},
+		{Name: "MoonScript", Extensions: []string{"moon"}, Interpreters: []string{"moon"}},
+		{Name: "mupad", Extensions: []string{"mu"}},
+		{Name: "Myghty", Extensions: []string{"myt"}},
+		{Name: "Nemerle", Extensions: []string{"n"}},
+		{Name: "Nginx", Aliases: []string{"nginx configuration file"}, Filenames: []string{"nginx.conf"}},
+		{Name: "Nimrod", Extensions: []string{"nim", "nimrod"}},
+		{Name: "NSIS", Extensions: []string{"nsi", "nsh"}},
+		{Name: "Nu", Aliases: []string{"nush"}, Extensions: []string{"nu"}, Interpreters: []string{"nush"}},
+		{Name: "NumPy"},
+		{Name: "ObjDump", Extensions: []string{"objdump"}},
+		{Name: "Objective-C", Aliases: []string{"obj-c", "objc"}, Extensions: []string{"mm"}},
+		{Name: "Objective-J", Aliases: []string{"obj-j", "objj"}, Extensions: []string{"j", "sj"}},
+		{Name: "OCaml", Extensions: []string{"ml", "mli", "mll", "mly"}, Interpreters: []string{"ocaml"}},
+		{Name: "Omgrofl", Extensions: []string{"omgrofl"}},
+		{Name: "ooc", Extensions: []string{"ooc"}},
+		{Name: "Opa", Extensions: []string{"opa"}},
+		{Name: "OpenCL", Extensions: []string{"opencl"}},
+		{Name: "OpenEdge ABL", Aliases: []string{"progress", "openedge", "abl"}, Extensions: []string{"p"}},
+		{Name: "Parrot", Extensions: []string{"parrot"}},
+		{Name: "Parrot Assembly", Aliases: []string{"pasm"}, Extensions: []string{"pasm"}},
+		{Name: "Parrot Internal Representation", Aliases: []string{"pir"}, Extensions: []string{"pir"}},
+		{Name: "Perl", Aliases: []string{"cperl"}, Extensions: []string{"pl", "pm", "pod", "perl", "t"}, Interpreters: []string{"perl"}},
+		{Name: "PHP", Aliases: []string{"inc"}, Extensions: []string{"php", "php3", "php4", "php5"}, Interpreters: []string{"php"}},
+		{Name: "Pike", Extensions: []string{"pike", "pmod"}},
+		{Name: "PogoScript", Extensions: []string{"pogo"}},
+		{Name: "PowerShell", Aliases: []string{"posh"}, Extensions: []string{"ps1", "psm1", "psd1"}},
+		{Name: "Prolog", Extensions: []string{"pro", "prolog"}},
+		{Name: "Puppet", Extensions: []string{"pp"}, Filenames: []string{"Modulefile"}},
+		{Name: "Pure Data", Extensions: []string{"pd"}},
+		{Name: "Python", Aliases: []string{"rusthon", "python3"}, Extensions: []string{"py", "pyw", "wsgi", "gyp"}, Filenames: []string{"SConstruct", "SConscript", "wscript"}, Interpreters: []string{"python", "python2", "python3"}},
+		{Name: "Python traceback", Extensions: []string{"pytb"}},
+		{Name: "R", Aliases: []string{"R", "Rscript"}, Extensions: []string{"r", "rd", "rsx"}, Interpreters: []string{"Rscript"}},
+		{Name: "Racket", Extensions: []string{"rkt", "rktl", "rktd", "scrbl"}, Interpreters: []string{"racket"}},
+		{Name: "Ragel in Ruby Host", Aliases: []string{"ragel-rb", "ragel-ruby"}, Extensions: []string{"rl"}},
+		{Name: "Raw token data", Aliases: []string{"raw"}, Extensions: []string{"raw"}},
+		{Name: "Rebol", Extensions: []string{"r2", "r3", "reb", "rebol"}},
+		{Name: "Redcode", Extensions: []string{"cw"}},
+		{Name: "reStructuredText", Aliases: []string{"rst"}, Extensions: []string{"rst", "rest"}},
+		{Name: "RHTML", Aliases: []string{"html+ruby"}, Extensions: []string{"rhtml"}},
+		{Name: "Rouge", Extensions: []string{"rg"}},
+		{Name: "Ruby", Aliases: []string{"jruby", "macruby", "rake", "rb", "rbx"}, Extensions: []string{"rb", "rbw", "rake", "gemspec", "podspec", "rbx", "ru", "thor"}, Filenames: []string{"Rakefile", "Gemfile", "Guardfile", "Capfile", "Vagrantfile", "Podfile", "Thorfile"}, Interpreters: []string{"ruby", "jruby", "macruby", "rake", "rbx"}},
+		{Name: "Rust", Aliases: []string{"rs"}, Extensions: []string{"rs"}},
+		{Name: "Sage", Extensions: []string{"sage"}},
+		{Name: "Sass", Extensions: []string{"sass"}},
+		{Name: "Scala", Extensions: []string{"scala", "sc"}, Interpreters: []string{"scala"}},
+		{Name: "Scheme", Extensions: []string{"scm", "sls", "ss"}, Interpreters: []string{"guile", "racket", "bigloo", "chicken"}},
+		{Name: "Scilab", Extensions: []string{"sci", "sce", "tst"}},
+		{Name: "SCSS", Extensions: []string{"scss"}},
+		{Name: "Self", Extensions: []string{"self"}},
+		{Name: "Shell", Aliases: []string{"sh", "bash", "zsh", "shell-script"}, Extensions: []string{"sh", "bash", "zsh", "ksh", "bats", "command", "tmux"}, Filenames: []string{".bashrc", ".bash_profile", ".zshrc", ".profile", ".bash_logout"}, Interpreters: []string{"bash", "sh", "zsh", "ksh", "dash", "ash"}},
+		{Name: "Smalltalk", Aliases: []string{"squeak"}, Extensions: []string{"st"}},
+		{Name: "Smarty", Extensions: []string{"tpl"}},
+		{Name: "SQL", Extensions: []string{"sql", "ddl", "prc", "tab", "udf", "viw"}},
+		{Name: "Standard ML", Aliases: []string{"sml"}, Extensions: []string{"sml", "sig", "fun"}},
+		{Name: "SuperCollider", Extensions: []string{"scd"}},
+		{Name: "Tcl", Extensions: []string{"tcl", "adp", "tm"}, Interpreters: []string{"tclsh", "wish"}},
+		{Name: "Tcsh", Extensions: []string{"tcsh", "csh"}, Interpreters: []string{"tcsh", "csh"}},
+		{Name: "Tea", Extensions: []string{"tea"}},
+		{Name: "TeX", Aliases: []string{"latex"}, Extensions: []string{"tex", "ltx", "sty", "aux", "bib", "toc", "dtx", "ins"}},
+		{Name: "Text", Aliases: []string{"plaintext", "plain", "fundamental"}, Extensions: []string{"txt", "text", "log"}, Filenames: []string{"README", "LICENSE", "COPYING", "INSTALL"}},
+		{Name: "Textile", Extensions: []string{"textile"}},
+		{Name: "TOML", Extensions: []string{"toml"}},
+		{Name: "Turing", Extensions: []string{"tu"}},
+		{Name: "Twig", Extensions: []string{"twig"}},
+		{Name: "TXL", Extensions: []string{"txl"}},
+		{Name: "TypeScript", Aliases: []string{"ts"}, Extensions: []string{"ts", "tsx"}},
+		{Name: "Vala", Extensions: []string{"vala", "vapi"}},
+		{Name: "Verilog", Extensions: []string{"vh", "sv"}},
+		{Name: "VHDL", Extensions: []string{"vhd", "vhdl", "vhf", "vhi", "vho", "vhs", "vht", "vhw"}},
+		{Name: "VimL", Aliases: []string{"vim"}, Extensions: []string{"vim"}, Filenames: []string{".vimrc", "vimrc", "gvimrc", ".gvimrc"}},
+		{Name: "Visual Basic", Aliases: []string{"vb.net", "vbnet", "vbscript"}, Extensions: []string{"vb", "bas", "frm", "frx", "vba", "vbs"}},
+		{Name: "XML", Aliases: []string{"rss", "xsd", "wsdl"}, Extensions: []string{"xml", "xsd", "xsl", "svg", "plist", "csproj", "vcxproj", "wsdl", "xaml", "xul", "rss", "atom", "kml", "nuspec"}},
+		{Name: "XProc", Extensions: []string{"xpl", "xproc"}},
+		{Name: "XQuery", Extensions: []string{"xquery", "xq", "xql", "xqm", "xqy"}},
+		{Name: "XS", Extensions: []string{"xs"}},
+		{Name: "XSLT", Aliases: []string{"xsl"}, Extensions: []string{"xslt"}},
+		{Name: "Xtend", Extensions: []string{"xtend"}},
+		{Name: "YAML", Extensions: []string{"yml", "yaml"}, Filenames: []string{".travis.yml"}},
+	}
+
+	languagesByName        = make(map[string]*language)
+	languagesByExtension   = make(map[string]*language)
+	languagesByFilename    = make(map[string]*language)
+	languagesByInterpreter = make(map[string]*language)
+
+	languageShebangRegex = regexp.MustCompile(`^#!\s*(?:\S*/)?(?:env\s+(?:-\S+\s+)*)?([A-Za-z0-9_.+-]+)`)
+
+	// Patterns matched against the start of a file with no other clues
+	// to its language, tried in order
+	languageContentHints = []struct {
+		regex    *regexp.Regexp
+		language string
+	}{
+		{regexp.MustCompile(`^<\?php`), "PHP"},
+		{regexp.MustCompile(`^<\?xml`), "XML"},
+		{regexp.MustCompile(`(?i)^<!DOCTYPE html|^<html`), "HTML"},
+		{regexp.MustCompile(`(?m)^package\s+[a-z_][a-z0-9_]*\s*$`), "Go"},
+		{regexp.MustCompile(`(?m)^(diff --git|--- \S+.*\n\+\+\+ \S+)`), "Diff"},
+		{regexp.MustCompile(`(?im)^\s*(SELECT\s.+\sFROM|INSERT\s+INTO|CREATE\s+(TABLE|INDEX|VIEW)|UPDATE\s+\S+\s+SET)\s`), "SQL"},
+		{regexp.MustCompile(`(?m)^(def\s+\w+\(.*\):|import\s+\w+$|from\s+\S+\s+import\s)`), "Python"},
+		{regexp.MustCompile(`(?m)^#include\s+[<"]`), "C"},
+		{regexp.MustCompile(`(?m)^(function\s+\w+\s*\(|var\s+\w+\s*=|(const|let)\s+\w+\s*=)`), "JavaScript"},
+		{regexp.MustCompile(`(?m)^#{1,6}\s+\S|^\S.*\n(===+|---+)\s*$`), "Markdown"},
+		{regexp.MustCompile(`^---\s*\n|(?m)^[A-Za-z_][A-Za-z0-9_-]*:(\s|$)`), "YAML"},
+	}
+)
+
+func init() {
+	for i := range languages {
+		lang := &languages[i]
+
+		// Markdown code fences are named using the lower case
+		// language name or one of its aliases
+		fences := make(map[string]bool)
+		for _, name := range append([]string{lang.Name}, lang.Aliases...) {
+			fence := strings.Replace(strings.ToLower(name), " ", "-", -1)
+			if !fences[fence] {
+				fences[fence] = true
+				lang.Fences = append(lang.Fences, fence)
+			}
+		}
+
+		languagesByName[strings.ToLower(lang.Name)] = lang
+		for _, alias := range lang.Aliases {
+			languagesByName[strings.ToLower(alias)] = lang
+		}
+		for _, fence := range lang.Fences {
+			languagesByName[fence] = lang
+		}
+
+		for _, ext := range lang.Extensions {
+			languagesByExtension[strings.ToLower(ext)] = lang
+		}
+
+		for _, filename := range lang.Filenames {
+			languagesByFilename[strings.ToLower(filename)] = lang
+		}
+
+		for _, interpreter := range lang.Interpreters {
+			languagesByInterpreter[interpreter] = lang
+		}
+	}
+}
+
+// languageNormalize returns the canonical name of a language given its
+// name, one of its aliases or its markdown fence name in any case, or an
+// empty string if the language isn't in the registry
+func languageNormalize(name string) string {
+	lang, ok := languagesByName[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return ""
+	}
+	return lang.Name
+}
+
+// languageDetectFilename will infer the language of a file from its name
+// alone, returning an empty string if the name gives nothing away
+func languageDetectFilename(filename string) string {
+	base := strings.ToLower(path.Base(filename))
+
+	if lang, ok := languagesByFilename[base]; ok {
+		return lang.Name
+	}
+
+	if ext := path.Ext(base); ext != "" {
+		if lang, ok := languagesByExtension[ext[1:]]; ok {
+			return lang.Name
+		}
+	}
+
+	return ""
+}
+
+// languageDetect will infer the language of a file from its name, its
+// shebang line, or failing that, what its contents look like
+func languageDetect(filename, contents string) string {
+	if lang := languageDetectFilename(filename); lang != "" {
+		return lang
+	}
+
+	if m := languageShebangRegex.FindStringSubmatch(contents); m != nil {
+		// Strip version numbers, as in python2.7 or ruby1.9
+		interpreter := strings.TrimRight(m[1], "0123456789.")
+		if lang, ok := languagesByInterpreter[m[1]]; ok {
+			return lang.Name
+		}
+		if lang, ok := languagesByInterpreter[interpreter]; ok {
+			return lang.Name
+		}
+	}
+
+	trimmed := strings.TrimSpace(contents)
+
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var v interface{}
+		if json.Unmarshal([]byte(trimmed), &v) == nil {
+			return "JSON"
+		}
+	}
+
+	head := trimmed
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+
+	for _, hint := range languageContentHints {
+		if hint.regex.MatchString(head) {
+			return hint.language
+		}
+	}
+
+	return LANG_TEXT
+}
@@ -0,0 +1,211 @@
+package summa
+
+import (
+	"bytes"
+	"database/sql"
+	_ "go-sqlite3"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+var (
+	// A mention is an @ followed by a username, as long as the @ doesn't
+	// follow a letter or digit as it does in an email address
+	mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_@])@([A-Za-z0-9_](?:[A-Za-z0-9_.-]*[A-Za-z0-9_])?)`)
+
+	mentionTagPattern = regexp.MustCompile(`<(/?)([A-Za-z0-9]+)[^>]*>`)
+
+	// Elements mentions aren't linked in, such as code
+	mentionSkipTags = map[string]bool{"a": true, "code": true, "pre": true}
+
+	// Block elements, at which any elements mentions aren't linked in are
+	// treated as closed, so unbalanced HTML can't hide the mentions after it
+	mentionBlockTags = map[string]bool{
+		"p": true, "div": true, "blockquote": true, "ul": true, "ol": true, "li": true,
+		"table": true, "tr": true, "td": true, "th": true, "hr": true,
+		"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	}
+)
+
+// sqlQueryer is anything that can run a query, a database or a transaction
+type sqlQueryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// mentionReplace calls fn for each username mentioned in text, replacing the
+// mention, @ included, with what fn returns
+func mentionReplace(text string, fn func(username string) string) string {
+	var b bytes.Buffer
+
+	last := 0
+	for _, m := range mentionPattern.FindAllStringSubmatchIndex(text, -1) {
+		// m[2] is where the @ is, just before the username
+		at, end := m[2]-1, m[3]
+
+		b.WriteString(text[last:at])
+		b.WriteString(fn(text[m[2]:m[3]]))
+		last = end
+	}
+	b.WriteString(text[last:])
+
+	return b.String()
+}
+
+// mentionParse returns the usernames mentioned in text, each only once
+func mentionParse(text string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+
+	mentionReplace(text, func(username string) string {
+		if !seen[username] {
+			seen[username] = true
+			usernames = append(usernames, username)
+		}
+		return "@" + username
+	})
+
+	return usernames
+}
+
+// mentionUsers returns which of the given usernames belong to users
+func mentionUsers(q sqlQueryer, usernames []string) (map[string]bool, error) {
+	users := make(map[string]bool)
+	if len(usernames) == 0 {
+		return users, nil
+	}
+
+	params := make([]interface{}, len(usernames))
+	for i, username := range usernames {
+		params[i] = username
+	}
+
+	rows, err := q.Query(
+		"SELECT username FROM user WHERE username IN ("+sqlPlaceholders(len(params))+")",
+		params...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var username string
+		rows.Scan(&username)
+		users[username] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// mentionLinkText links the mentions of users in plain text to their
+// profiles, returning HTML
+func mentionLinkText(text string, users map[string]bool) string {
+	return mentionReplace(template.HTMLEscapeString(text), func(username string) string {
+		return mentionAnchor(username, users)
+	})
+}
+
+// mentionLinkHTML links the mentions of users in HTML to their profiles.
+// Mentions inside code, preformatted text and existing links are left alone
+func mentionLinkHTML(html string, users map[string]bool) string {
+	var b bytes.Buffer
+
+	// The number of elements open that mentions mustn't be linked in
+	skip := 0
+
+	last := 0
+	for _, m := range mentionTagPattern.FindAllStringSubmatchIndex(html, -1) {
+		text := html[last:m[0]]
+		if skip == 0 {
+			text = mentionReplace(text, func(username string) string {
+				return mentionAnchor(username, users)
+			})
+		}
+		b.WriteString(text)
+		b.WriteString(html[m[0]:m[1]])
+
+		tag := strings.ToLower(html[m[4]:m[5]])
+		closing := m[3] > m[2]
+		selfClosing := strings.HasSuffix(html[m[0]:m[1]], "/>")
+
+		// Self-closing tags such as <code/> don't open an element
+		if mentionBlockTags[tag] {
+			skip = 0
+		} else if mentionSkipTags[tag] && !selfClosing {
+			if !closing {
+				skip++
+			} else if skip > 0 {
+				skip--
+			}
+		}
+
+		last = m[1]
+	}
+
+	text := html[last:]
+	if skip == 0 {
+		text = mentionReplace(text, func(username string) string {
+			return mentionAnchor(username, users)
+		})
+	}
+	b.WriteString(text)
+
+	return b.String()
+}
+
+// mentionAnchor returns a link to the profile of a mentioned user, or the
+// mention as it was if there is no such user
+func mentionAnchor(username string, users map[string]bool) string {
+	if !users[username] {
+		return "@" + username
+	}
+
+	return `<a href="#/profile/` + username + `" class="mention">@` + username + `</a>`
+}
+
+// mentionNotify will notify the users mentioned in text by actor on a snippet,
+// or on one of its comments if a comment id is given. Users already mentioned
+// in oldText, the text it replaces, aren't notified again
+func mentionNotify(tx *sql.Tx, actor, snippetID string, commentID int64, text, oldText string) error {
+	mentioned := make(map[string]bool)
+	for _, username := range mentionParse(oldText) {
+		mentioned[username] = true
+	}
+	mentioned[actor] = true
+
+	var usernames []string
+	for _, username := range mentionParse(text) {
+		if !mentioned[username] {
+			usernames = append(usernames, username)
+		}
+	}
+
+	users, err := mentionUsers(tx, usernames)
+	if err != nil {
+		return err
+	}
+
+	for _, username := range usernames {
+		if !users[username] {
+			continue
+		}
+
+		err = notificationCreate(tx, &notification{
+			Username:  username,
+			Type:      NOTIFICATION_MENTION,
+			Actor:     actor,
+			SnippetID: snippetID,
+			CommentID: commentID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
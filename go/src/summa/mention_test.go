@@ -0,0 +1,74 @@
+package summa
+
+import (
+	"testing"
+)
+
+func TestMentionLinkHTML(t *testing.T) {
+	users := map[string]bool{"alice": true, "bob": true}
+	alice := `<a href="#/profile/alice" class="mention">@alice</a>`
+	bob := `<a href="#/profile/bob" class="mention">@bob</a>`
+
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "plain mention",
+			html: "<p>Hi @alice</p>",
+			want: "<p>Hi " + alice + "</p>",
+		},
+		{
+			name: "email address",
+			html: "<p>mail alice@example.com</p>",
+			want: "<p>mail alice@example.com</p>",
+		},
+		{
+			name: "unknown user",
+			html: "<p>Hi @carol</p>",
+			want: "<p>Hi @carol</p>",
+		},
+		{
+			name: "inside code",
+			html: "<p><code>@alice</code> and @bob</p>",
+			want: "<p><code>@alice</code> and " + bob + "</p>",
+		},
+		{
+			name: "inside preformatted code",
+			html: "<pre><code>@alice\n</code></pre><p>@bob</p>",
+			want: "<pre><code>@alice\n</code></pre><p>" + bob + "</p>",
+		},
+		{
+			name: "inside a link",
+			html: `<p><a href="https://example.com">@alice</a> @bob</p>`,
+			want: `<p><a href="https://example.com">@alice</a> ` + bob + "</p>",
+		},
+		{
+			name: "uppercase tags",
+			html: "<P><CODE>@alice</CODE> @bob</P>",
+			want: "<P><CODE>@alice</CODE> " + bob + "</P>",
+		},
+		{
+			name: "self-closing tag",
+			html: "<p><code/> @alice</p>",
+			want: "<p><code/> " + alice + "</p>",
+		},
+		{
+			name: "unclosed link",
+			html: `<p><a href="https://example.com">@alice</p><p>@bob</p>`,
+			want: `<p><a href="https://example.com">@alice</p><p>` + bob + "</p>",
+		},
+		{
+			name: "unmatched closing tag",
+			html: "<p></code>@alice <code>@bob</code></p>",
+			want: "<p></code>" + alice + " <code>@bob</code></p>",
+		},
+	}
+
+	for _, test := range tests {
+		if got := mentionLinkHTML(test.html, users); got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
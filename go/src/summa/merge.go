@@ -0,0 +1,296 @@
+package summa
+
+import (
+	"strings"
+)
+
+const (
+	MERGE_MARKER_YOURS     = "<<<<<<< yours"
+	MERGE_MARKER_BASE      = "||||||| base"
+	MERGE_MARKER_SEPARATOR = "======="
+	MERGE_MARKER_CURRENT   = ">>>>>>> current"
+)
+
+const (
+	// Both sides changed the same lines of a text file
+	MERGE_CONFLICT_CONTENTS = "contents"
+
+	// One side changed a file the other side removed
+	MERGE_CONFLICT_REMOVED = "removed"
+
+	// Both sides changed a binary file
+	MERGE_CONFLICT_BINARY = "binary"
+
+	// Both sides gave the same filename to different files
+	MERGE_CONFLICT_FILENAME = "filename"
+)
+
+// mergeConflict is a region of a file both sides changed differently
+type mergeConflict struct {
+	Line    int    `json:"line"`
+	Base    string `json:"base"`
+	Yours   string `json:"yours"`
+	Current string `json:"current"`
+}
+
+// snippetFileMerge describes a file that couldn't be merged cleanly. The
+// contents of text files hold every conflict between markers, starting on
+// the line given for each
+type snippetFileMerge struct {
+	Filename  string          `json:"filename"`
+	Reason    string          `json:"reason"`
+	Contents  string          `json:"contents,omitempty"`
+	Conflicts []mergeConflict `json:"conflicts,omitempty"`
+}
+
+// mergeMatches returns, for each line of base, the index of the same line in
+// other, or -1 if it was changed or removed
+func mergeMatches(base, other []string) []int {
+	matches := make([]int, len(base))
+
+	i, j := 0, 0
+	for _, edit := range diffLines(base, other) {
+		switch edit.Op {
+		case DIFF_EQUAL:
+			matches[i] = j
+			i++
+			j++
+		case DIFF_DELETE:
+			matches[i] = -1
+			i++
+		case DIFF_INSERT:
+			j++
+		}
+	}
+
+	return matches
+}
+
+// mergeLinesEqual returns true if two lists of lines are the same
+func mergeLinesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeLines combines the changes both yours and current made to base. The
+// lines all three have in common split them into chunks, and a chunk only
+// one side changed takes that side's lines. Chunks both sides changed
+// differently are conflicts, which are written out between markers
+func mergeLines(base, yours, current []string) ([]string, []mergeConflict) {
+	var merged []string
+	var conflicts []mergeConflict
+
+	yoursMatches := mergeMatches(base, yours)
+	currentMatches := mergeMatches(base, current)
+
+	i, y, c := 0, 0, 0
+	for {
+		for i < len(base) && yoursMatches[i] == y && currentMatches[i] == c {
+			merged = append(merged, base[i])
+			i++
+			y++
+			c++
+		}
+
+		// Find the next line left alone by both sides
+		k := i
+		for k < len(base) && (yoursMatches[k] < 0 || currentMatches[k] < 0) {
+			k++
+		}
+
+		ky, kc := len(yours), len(current)
+		if k < len(base) {
+			ky, kc = yoursMatches[k], currentMatches[k]
+		}
+
+		if k == i && ky == y && kc == c {
+			break
+		}
+
+		baseChunk, yoursChunk, currentChunk := base[i:k], yours[y:ky], current[c:kc]
+
+		switch {
+		case mergeLinesEqual(yoursChunk, baseChunk):
+			merged = append(merged, currentChunk...)
+
+		case mergeLinesEqual(currentChunk, baseChunk), mergeLinesEqual(yoursChunk, currentChunk):
+			merged = append(merged, yoursChunk...)
+
+		default:
+			conflicts = append(conflicts, mergeConflict{
+				Line:    len(merged) + 1,
+				Base:    strings.Join(baseChunk, "\n"),
+				Yours:   strings.Join(yoursChunk, "\n"),
+				Current: strings.Join(currentChunk, "\n"),
+			})
+
+			merged = append(merged, MERGE_MARKER_YOURS)
+			merged = append(merged, yoursChunk...)
+			merged = append(merged, MERGE_MARKER_BASE)
+			merged = append(merged, baseChunk...)
+			merged = append(merged, MERGE_MARKER_SEPARATOR)
+			merged = append(merged, currentChunk...)
+			merged = append(merged, MERGE_MARKER_CURRENT)
+		}
+
+		i, y, c = k, ky, kc
+	}
+
+	return merged, conflicts
+}
+
+// mergeText combines the changes yours and current made to base, returning
+// the merged text and any conflicts it contains
+func mergeText(base, yours, current string) (string, []mergeConflict) {
+	lines, conflicts := mergeLines(diffSplitLines(base), diffSplitLines(yours), diffSplitLines(current))
+	if len(lines) == 0 {
+		return "", conflicts
+	}
+
+	// The final line ending is kept as current has it, unless yours
+	// is the one that changed it
+	newline := strings.HasSuffix(current, "\n")
+	if strings.HasSuffix(yours, "\n") != strings.HasSuffix(base, "\n") {
+		newline = strings.HasSuffix(yours, "\n")
+	}
+
+	merged := strings.Join(lines, "\n")
+	if newline {
+		merged += "\n"
+	}
+
+	return merged, conflicts
+}
+
+// mergeFilesEqual returns true if two versions of a file have the same
+// contents, or are both missing
+func mergeFilesEqual(a, b *snippetFile) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Contents == b.Contents
+}
+
+// mergeFiles combines the changes yours and current made to the files of
+// base, file by file. Renames made by yours are given from the filenames of
+// base to those of yours. The merged files are returned, or the files that
+// couldn't be merged if there were any
+func mergeFiles(base, yours, current snippetFiles, renames map[string]string) (snippetFiles, []snippetFileMerge) {
+	var merged snippetFiles
+	var failed []snippetFileMerge
+
+	yoursByName := make(map[string]*snippetFile)
+	for i := range yours {
+		yoursByName[yours[i].Filename] = &yours[i]
+	}
+
+	currentByName := make(map[string]*snippetFile)
+	for i := range current {
+		currentByName[current[i].Filename] = &current[i]
+	}
+
+	seen := make(map[*snippetFile]bool)
+
+	merge := func(b, y, c *snippetFile) {
+		seen[y] = true
+		seen[c] = true
+
+		// Files yours renamed keep their new name
+		var filename string
+		switch {
+		case y != nil:
+			filename = y.Filename
+		case c != nil:
+			filename = c.Filename
+		default:
+			filename = b.Filename
+		}
+
+		var file *snippetFile
+		switch {
+		case mergeFilesEqual(y, c), mergeFilesEqual(c, b):
+			file = y
+
+		case mergeFilesEqual(y, b):
+			file = c
+
+		case y == nil || c == nil:
+			failed = append(failed, snippetFileMerge{Filename: filename, Reason: MERGE_CONFLICT_REMOVED})
+			return
+
+		case y.Binary || IsBinary([]byte(y.Contents)) || IsBinary([]byte(c.Contents)) ||
+			(b != nil && IsBinary([]byte(b.Contents))):
+			failed = append(failed, snippetFileMerge{Filename: filename, Reason: MERGE_CONFLICT_BINARY})
+			return
+
+		default:
+			var baseContents string
+			if b != nil {
+				baseContents = b.Contents
+			}
+
+			contents, conflicts := mergeText(baseContents, y.Contents, c.Contents)
+			if len(conflicts) > 0 {
+				failed = append(failed, snippetFileMerge{
+					Filename:  filename,
+					Reason:    MERGE_CONFLICT_CONTENTS,
+					Contents:  contents,
+					Conflicts: conflicts,
+				})
+				return
+			}
+
+			file = &snippetFile{}
+			*file = *y
+			file.Contents = contents
+			file.Size = int64(len(contents))
+		}
+
+		if file != nil {
+			f := *file
+			f.Filename = filename
+			merged = append(merged, f)
+		}
+	}
+
+	for i := range base {
+		b := &base[i]
+
+		yoursName, ok := renames[b.Filename]
+		if !ok {
+			yoursName = b.Filename
+		}
+
+		merge(b, yoursByName[yoursName], currentByName[b.Filename])
+	}
+
+	// Files that base didn't have were added by one side or both
+	for i := range yours {
+		if !seen[&yours[i]] {
+			merge(nil, &yours[i], currentByName[yours[i].Filename])
+		}
+	}
+
+	for i := range current {
+		switch {
+		case seen[&current[i]]:
+		case merged.contains(current[i].Filename):
+			failed = append(failed, snippetFileMerge{Filename: current[i].Filename, Reason: MERGE_CONFLICT_FILENAME})
+		default:
+			merge(nil, nil, &current[i])
+		}
+	}
+
+	if len(failed) > 0 {
+		return nil, failed
+	}
+
+	return merged, nil
+}
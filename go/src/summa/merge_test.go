@@ -0,0 +1,247 @@
+package summa
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeText(t *testing.T) {
+	tests := []struct {
+		name                 string
+		base, yours, current string
+		want                 string
+		conflicts            []mergeConflict
+	}{
+		{
+			name:    "unchanged",
+			base:    "one\ntwo\n",
+			yours:   "one\ntwo\n",
+			current: "one\ntwo\n",
+			want:    "one\ntwo\n",
+		},
+		{
+			name:    "only yours changed",
+			base:    "one\ntwo\nthree\n",
+			yours:   "one\n2\nthree\n",
+			current: "one\ntwo\nthree\n",
+			want:    "one\n2\nthree\n",
+		},
+		{
+			name:    "only current changed",
+			base:    "one\ntwo\nthree\n",
+			yours:   "one\ntwo\nthree\n",
+			current: "one\ntwo\n3\n",
+			want:    "one\ntwo\n3\n",
+		},
+		{
+			name:    "separate changes",
+			base:    "one\ntwo\nthree\nfour\nfive\n",
+			yours:   "1\ntwo\nthree\nfour\nfive\n",
+			current: "one\ntwo\nthree\nfour\n5\n",
+			want:    "1\ntwo\nthree\nfour\n5\n",
+		},
+		{
+			name:    "insertions at both ends",
+			base:    "one\ntwo\n",
+			yours:   "zero\none\ntwo\n",
+			current: "one\ntwo\nthree\n",
+			want:    "zero\none\ntwo\nthree\n",
+		},
+		{
+			name:    "same change on both sides",
+			base:    "one\ntwo\nthree\n",
+			yours:   "one\n2\nthree\n",
+			current: "one\n2\nthree\n",
+			want:    "one\n2\nthree\n",
+		},
+		{
+			name:    "overlapping changes",
+			base:    "one\ntwo\nthree\n",
+			yours:   "one\nyours\nthree\n",
+			current: "one\ncurrent\nthree\n",
+			want: "one\n" +
+				MERGE_MARKER_YOURS + "\nyours\n" +
+				MERGE_MARKER_BASE + "\ntwo\n" +
+				MERGE_MARKER_SEPARATOR + "\ncurrent\n" +
+				MERGE_MARKER_CURRENT + "\n" +
+				"three\n",
+			conflicts: []mergeConflict{
+				{Line: 2, Base: "two", Yours: "yours", Current: "current"},
+			},
+		},
+		{
+			name:    "adjacent changes",
+			base:    "one\ntwo\nthree\n",
+			yours:   "1\ntwo\nthree\n",
+			current: "one\n2\nthree\n",
+			want: MERGE_MARKER_YOURS + "\n1\ntwo\n" +
+				MERGE_MARKER_BASE + "\none\ntwo\n" +
+				MERGE_MARKER_SEPARATOR + "\none\n2\n" +
+				MERGE_MARKER_CURRENT + "\n" +
+				"three\n",
+			conflicts: []mergeConflict{
+				{Line: 1, Base: "one\ntwo", Yours: "1\ntwo", Current: "one\n2"},
+			},
+		},
+		{
+			name:    "conflict line counts earlier conflicts",
+			base:    "a\nb\nc\nd\ne\n",
+			yours:   "A\nb\nc\nd\nE\n",
+			current: "x\nb\nc\nd\ny\n",
+			want: MERGE_MARKER_YOURS + "\nA\n" +
+				MERGE_MARKER_BASE + "\na\n" +
+				MERGE_MARKER_SEPARATOR + "\nx\n" +
+				MERGE_MARKER_CURRENT + "\n" +
+				"b\nc\nd\n" +
+				MERGE_MARKER_YOURS + "\nE\n" +
+				MERGE_MARKER_BASE + "\ne\n" +
+				MERGE_MARKER_SEPARATOR + "\ny\n" +
+				MERGE_MARKER_CURRENT + "\n",
+			conflicts: []mergeConflict{
+				{Line: 1, Base: "a", Yours: "A", Current: "x"},
+				{Line: 11, Base: "e", Yours: "E", Current: "y"},
+			},
+		},
+		{
+			name:    "yours removes the trailing newline",
+			base:    "one\n",
+			yours:   "one",
+			current: "one\ntwo\n",
+			want:    "one\ntwo",
+		},
+		{
+			name:    "current removes the trailing newline",
+			base:    "one\n",
+			yours:   "1\n",
+			current: "one",
+			want:    "1",
+		},
+		{
+			name:    "everything removed",
+			base:    "one\n",
+			yours:   "",
+			current: "one\n",
+			want:    "",
+		},
+	}
+
+	for _, test := range tests {
+		got, conflicts := mergeText(test.base, test.yours, test.current)
+		if got != test.want {
+			t.Errorf("%s: got\n%q\nwant\n%q", test.name, got, test.want)
+		}
+		if !reflect.DeepEqual(conflicts, test.conflicts) {
+			t.Errorf("%s: got conflicts %+v, want %+v", test.name, conflicts, test.conflicts)
+		}
+	}
+}
+
+func TestMergeFiles(t *testing.T) {
+	tests := []struct {
+		name                 string
+		base, yours, current snippetFiles
+		renames              map[string]string
+		want                 map[string]string
+		failed               map[string]string
+	}{
+		{
+			name:    "changes to different files",
+			base:    snippetFiles{{Filename: "a.txt", Contents: "a\n"}, {Filename: "b.txt", Contents: "b\n"}},
+			yours:   snippetFiles{{Filename: "a.txt", Contents: "yours\n"}, {Filename: "b.txt", Contents: "b\n"}},
+			current: snippetFiles{{Filename: "a.txt", Contents: "a\n"}, {Filename: "b.txt", Contents: "current\n"}},
+			want:    map[string]string{"a.txt": "yours\n", "b.txt": "current\n"},
+		},
+		{
+			name:    "changes to the same file",
+			base:    snippetFiles{{Filename: "a.txt", Contents: "one\ntwo\nthree\n"}},
+			yours:   snippetFiles{{Filename: "a.txt", Contents: "1\ntwo\nthree\n"}},
+			current: snippetFiles{{Filename: "a.txt", Contents: "one\ntwo\n3\n"}},
+			want:    map[string]string{"a.txt": "1\ntwo\n3\n"},
+		},
+		{
+			name:    "files added on each side",
+			base:    snippetFiles{},
+			yours:   snippetFiles{{Filename: "a.txt", Contents: "a\n"}},
+			current: snippetFiles{{Filename: "b.txt", Contents: "b\n"}},
+			want:    map[string]string{"a.txt": "a\n", "b.txt": "b\n"},
+		},
+		{
+			name:    "rename by yours and change by current",
+			base:    snippetFiles{{Filename: "a.txt", Contents: "one\ntwo\nthree\n"}},
+			yours:   snippetFiles{{Filename: "b.txt", Contents: "one\ntwo\nthree\nfour\n"}},
+			current: snippetFiles{{Filename: "a.txt", Contents: "zero\none\ntwo\nthree\n"}},
+			renames: map[string]string{"a.txt": "b.txt"},
+			want:    map[string]string{"b.txt": "zero\none\ntwo\nthree\nfour\n"},
+		},
+		{
+			name:    "rename onto a file current added",
+			base:    snippetFiles{{Filename: "a.txt", Contents: "a\n"}},
+			yours:   snippetFiles{{Filename: "b.txt", Contents: "a\n"}},
+			current: snippetFiles{{Filename: "a.txt", Contents: "a\n"}, {Filename: "b.txt", Contents: "b\n"}},
+			renames: map[string]string{"a.txt": "b.txt"},
+			failed:  map[string]string{"b.txt": MERGE_CONFLICT_FILENAME},
+		},
+		{
+			name:    "removed by yours and unchanged by current",
+			base:    snippetFiles{{Filename: "a.txt", Contents: "a\n"}, {Filename: "b.txt", Contents: "b\n"}},
+			yours:   snippetFiles{{Filename: "b.txt", Contents: "b\n"}},
+			current: snippetFiles{{Filename: "a.txt", Contents: "a\n"}, {Filename: "b.txt", Contents: "b\n"}},
+			want:    map[string]string{"b.txt": "b\n"},
+		},
+		{
+			name:    "removed by yours and changed by current",
+			base:    snippetFiles{{Filename: "a.txt", Contents: "a\n"}, {Filename: "b.txt", Contents: "b\n"}},
+			yours:   snippetFiles{{Filename: "b.txt", Contents: "b\n"}},
+			current: snippetFiles{{Filename: "a.txt", Contents: "current\n"}, {Filename: "b.txt", Contents: "b\n"}},
+			failed:  map[string]string{"a.txt": MERGE_CONFLICT_REMOVED},
+		},
+		{
+			name:    "changed by yours and removed by current",
+			base:    snippetFiles{{Filename: "a.txt", Contents: "a\n"}},
+			yours:   snippetFiles{{Filename: "a.txt", Contents: "yours\n"}},
+			current: snippetFiles{},
+			failed:  map[string]string{"a.txt": MERGE_CONFLICT_REMOVED},
+		},
+		{
+			name:    "binary changed on both sides",
+			base:    snippetFiles{{Filename: "a.bin", Contents: "\x00base"}},
+			yours:   snippetFiles{{Filename: "a.bin", Contents: "\x00yours", Binary: true}},
+			current: snippetFiles{{Filename: "a.bin", Contents: "\x00current"}},
+			failed:  map[string]string{"a.bin": MERGE_CONFLICT_BINARY},
+		},
+		{
+			name:    "conflicting contents",
+			base:    snippetFiles{{Filename: "a.txt", Contents: "a\n"}},
+			yours:   snippetFiles{{Filename: "a.txt", Contents: "yours\n"}},
+			current: snippetFiles{{Filename: "a.txt", Contents: "current\n"}},
+			failed:  map[string]string{"a.txt": MERGE_CONFLICT_CONTENTS},
+		},
+	}
+
+	for _, test := range tests {
+		merged, failed := mergeFiles(test.base, test.yours, test.current, test.renames)
+
+		var got map[string]string
+		if merged != nil {
+			got = make(map[string]string)
+			for _, file := range merged {
+				got[file.Filename] = file.Contents
+			}
+		}
+
+		var gotFailed map[string]string
+		if failed != nil {
+			gotFailed = make(map[string]string)
+			for _, file := range failed {
+				gotFailed[file.Filename] = file.Reason
+			}
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got files %v, want %v", test.name, got, test.want)
+		}
+		if !reflect.DeepEqual(gotFailed, test.failed) {
+			t.Errorf("%s: got failures %v, want %v", test.name, gotFailed, test.failed)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package summa
+
+import (
+	"database/sql"
+	_ "go-sqlite3"
+)
+
+const (
+	NOTIFICATIONS_LIMIT = 100
+
+	// Someone mentioned the user in a snippet's description or a comment
+	NOTIFICATION_MENTION = "mention"
+)
+
+type notification struct {
+	ID               int64  `json:"id"`
+	Username         string `json:"-"`
+	Type             string `json:"type"`
+	Actor            string `json:"actor"`
+	ActorDisplayName string `json:"actorDisplayName"`
+	SnippetID        string `json:"snippetId"`
+	CommentID        int64  `json:"commentId,omitempty"`
+	Description      string `json:"description"`
+	Created          int64  `json:"created"`
+	Read             bool   `json:"read"`
+}
+
+type notifications []notification
+
+// notificationCreate will store a new notification for a user
+func notificationCreate(tx *sql.Tx, n *notification) error {
+	n.Created = UnixMilliseconds()
+
+	result, err := tx.Exec(
+		"INSERT INTO user_notification VALUES (NULL,?,?,?,?,?,?,0)",
+		n.Username,
+		n.Type,
+		n.Actor,
+		n.SnippetID,
+		n.CommentID,
+		n.Created,
+	)
+	if err != nil {
+		return err
+	}
+
+	n.ID, err = result.LastInsertId()
+	return err
+}
+
+// notificationsFetch will fetch the latest notifications of a user about
+// snippets that haven't been deleted, optionally only those not yet read
+func notificationsFetch(db *sql.DB, username string, unreadOnly bool) (notifications, error) {
+	list := make(notifications, 0)
+
+	query := "SELECT n.notification_id,n.type,n.actor,u.display_name,n.snippet_id,n.comment_id," +
+		"s.description,n.created,n.read FROM user_notification n JOIN user u ON u.username=n.actor " +
+		"JOIN snippet s ON s.snippet_id=n.snippet_id WHERE n.username=? AND s.deleted=0 "
+	if unreadOnly {
+		query += "AND n.read=0 "
+	}
+	query += "ORDER BY n.created DESC LIMIT ?"
+
+	rows, err := db.Query(query, username, NOTIFICATIONS_LIMIT)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		n := notification{Username: username}
+
+		rows.Scan(
+			&n.ID,
+			&n.Type,
+			&n.Actor,
+			&n.ActorDisplayName,
+			&n.SnippetID,
+			&n.CommentID,
+			&n.Description,
+			&n.Created,
+			&n.Read,
+		)
+
+		list = append(list, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// notificationsCountUnread returns how many notifications a user hasn't read
+func notificationsCountUnread(db *sql.DB, username string) (int64, error) {
+	var count int64
+
+	row := db.QueryRow(
+		"SELECT COUNT(*) FROM user_notification n JOIN snippet s ON s.snippet_id=n.snippet_id "+
+			"WHERE n.username=? AND n.read=0 AND s.deleted=0",
+		username,
+	)
+	err := row.Scan(&count)
+
+	return count, err
+}
+
+// notificationsMarkRead will mark the notifications of a user with the given
+// ids as read, or all of them if no ids are given
+func notificationsMarkRead(db *sql.DB, username string, ids []int64) error {
+	query := "UPDATE user_notification SET read=1 WHERE username=?"
+	params := []interface{}{username}
+
+	if len(ids) > 0 {
+		query += " AND notification_id IN (" + sqlPlaceholders(len(ids)) + ")"
+		for _, id := range ids {
+			params = append(params, id)
+		}
+	}
+
+	_, err := db.Exec(query, params...)
+
+	return err
+}
@@ -0,0 +1,125 @@
+package summa
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// handleRawRequest serves the contents of a single snippet file, as
+// requested by a path of the form /raw/<id>/<filename>. The file is
+// served as it was at the revision given by the optional rev parameter
+func handleRawRequest(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Server", "Summa/1.0.0")
+
+	if req.Method != "GET" && req.Method != "HEAD" {
+		http.Error(w, METHOD_NOT_ALLOWED, http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/raw/"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.NotFound(w, req)
+		return
+	}
+	id, filename := parts[0], parts[1]
+	rev := req.URL.Query().Get("rev")
+
+	db := httpOpenAuthenticated(w, req)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+
+	var content io.ReadSeeker
+	var modTime time.Time
+
+	if rev == "" {
+		// Only files known to belong to the snippet are served, which
+		// also keeps the path from wandering outside of the repository
+		file, err := snippetFetchFile(db, id, filename)
+		if err != nil {
+			httpInternalError(w, "Could not fetch snippet file", err)
+			return
+		}
+
+		if file == nil {
+			http.NotFound(w, req)
+			return
+		}
+
+		f, err := os.Open(path.Join(repoPath(id), file.Filename))
+		if err != nil {
+			httpInternalError(w, "Could not open snippet file", err)
+			return
+		}
+		defer f.Close()
+
+		stat, err := f.Stat()
+		if err != nil {
+			httpInternalError(w, "Could not stat snippet file", err)
+			return
+		}
+
+		content = f
+		modTime = stat.ModTime()
+	} else {
+		exists, err := snippetExists(db, id)
+		if err != nil {
+			httpInternalError(w, "Could not check if snippet exists", err)
+			return
+		}
+
+		if !exists {
+			http.NotFound(w, req)
+			return
+		}
+
+		contents, err := repoReadFile(id, rev, filename)
+		if err != nil {
+			httpInternalError(w, "Could not read snippet file", err)
+			return
+		}
+
+		if contents == nil {
+			http.NotFound(w, req)
+			return
+		}
+
+		content = bytes.NewReader(contents)
+	}
+
+	head := make([]byte, FILE_SNIFF_LEN)
+	n, _ := io.ReadFull(content, head)
+	head = head[:n]
+
+	_, err := content.Seek(0, os.SEEK_SET)
+	if err != nil {
+		httpInternalError(w, "Could not read snippet file", err)
+		return
+	}
+
+	// Files are served from the same origin as the app, so nothing is
+	// served as a type a browser might run. Text is always plain text,
+	// and anything else is downloaded rather than displayed
+	header := w.Header()
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Content-Security-Policy", "sandbox")
+
+	if IsBinary(head) {
+		header.Set("Content-Type", fileMimeType(filename, head))
+		header.Set(
+			"Content-Disposition",
+			fmt.Sprintf("attachment; filename=%q", path.Base(filename)),
+		)
+	} else {
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+
+	http.ServeContent(w, req, filename, modTime, content)
+}
@@ -1,15 +1,48 @@
 package summa
 
 import (
+	"crypto/sha1"
+	"fmt"
 	"os"
 	"path"
+	"sort"
 )
 
+const (
+	CHANGE_ADDED    = "added"
+	CHANGE_MODIFIED = "modified"
+	CHANGE_RENAMED  = "renamed"
+	CHANGE_REMOVED  = "removed"
+
+	// The percentage of lines a removed and an added file must
+	// have in common to be treated as a rename
+	RENAME_SIMILARITY_MIN = 50
+)
+
+// snippetFileChange describes how a file changed between two revisions
+type snippetFileChange struct {
+	Status      string `json:"status"`
+	Filename    string `json:"filename"`
+	OldFilename string `json:"oldFilename,omitempty"`
+	Similarity  int    `json:"similarity,omitempty"`
+	Binary      bool   `json:"binary,omitempty"`
+	Patch       string `json:"patch,omitempty"`
+}
+
 // repoPath will return the absolute path to the repository
 func repoPath(id string) string {
 	return path.Join(config.GitRoot(), id[:2], id[2:])
 }
 
+// repoBlobID returns the id git gives to a blob with the given contents
+func repoBlobID(contents []byte) string {
+	hasher := sha1.New()
+	fmt.Fprintf(hasher, "blob %d\x00", len(contents))
+	hasher.Write(contents)
+
+	return fmt.Sprintf("%x", hasher.Sum(nil))
+}
+
 // repoCreate will create a new repository in the filesystem
 func repoCreate(id string, u *User, files snippetFiles) error {
 	var err error
@@ -36,74 +69,453 @@ func repoCreate(id string, u *User, files snippetFiles) error {
 	}
 
 	for _, file := range files {
-		var f *os.File
-		filePath := path.Join(absPath, file.Filename)
-		f, err = os.Create(filePath)
+		err = repoWriteFile(absPath, index, file)
 		if err != nil {
 			return err
 		}
-		_, err = f.WriteString(file.Contents)
-		if err != nil {
-			return err
+	}
+
+	return repo.Commit(u.DisplayName, u.Email, "")
+}
+
+// renameCandidate pairs a removed file with an added file it may have been
+// renamed to
+type renameCandidate struct {
+	oldFile, newFile *snippetFile
+	similarity       int
+}
+
+// renameCandidates sorts the most similar pairs first
+type renameCandidates []renameCandidate
+
+func (c renameCandidates) Len() int           { return len(c) }
+func (c renameCandidates) Less(i, j int) bool { return c[i].similarity > c[j].similarity }
+func (c renameCandidates) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+
+// repoUpdate will commit a new set of files to a repository, renaming files as
+// given by renames or, failing that, detected by their contents. Files which
+// haven't changed are left alone. The changes made are returned
+func repoUpdate(id string, u *User, files snippetFiles, renames map[string]string, message string) ([]snippetFileChange, error) {
+	absPath := repoPath(id)
+
+	repo, err := GitRepositoryOpen(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := repo.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	oldFiles, err := repoReadFiles(id, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	changes := repoChanges(oldFiles, files, renames, false)
+
+	// Everything is removed before anything is written, so that
+	// files can be renamed over one another and a file can become
+	// a directory
+	for _, change := range changes {
+		filename := change.OldFilename
+		if change.Status == CHANGE_REMOVED {
+			filename = change.Filename
 		}
 
-		err = index.Add(file.Filename)
-		if err != nil {
-			return err
+		if filename != "" {
+			err = repoRemoveFile(absPath, index, filename)
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	return repo.Commit(u.DisplayName, u.Email)
+	for _, change := range changes {
+		if change.Status == CHANGE_REMOVED {
+			continue
+		}
+
+		for _, file := range files {
+			if file.Filename == change.Filename {
+				err = repoWriteFile(absPath, index, file)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return changes, repo.Commit(u.DisplayName, u.Email, message)
 }
 
-func repoUpdate(id string, u *User, oldFiles, newFiles snippetFiles) error {
-	absPath := repoPath(id)
+// repoNote will record a commit that changes no files, to
+// note an event in the history of a snippet
+func repoNote(id string, u *User, message string) error {
+	repo, err := GitRepositoryOpen(repoPath(id))
+	if err != nil {
+		return err
+	}
 
-	repo, err := GitRepositoryOpen(absPath)
+	return repo.Commit(u.DisplayName, u.Email, message)
+}
+
+// repoCommitMessage returns the message for a commit made by u to a snippet
+// written together with others, crediting each of them in the trailers git
+// hosting tools use for co-authors
+func repoCommitMessage(u *User, authors []snippetAuthor) string {
+	var message string
+
+	for _, author := range authors {
+		if author.Username != u.Username {
+			message += fmt.Sprintf("Co-authored-by: %s <%s>\n", author.DisplayName, author.Email)
+		}
+	}
+
+	if message != "" {
+		message = "Update snippet\n\n" + message
+	}
+
+	return message
+}
+
+// repoWriteFile will write a file into the working directory of a repository,
+// creating any parent directories it needs, and add it to the index
+func repoWriteFile(absPath string, index *GitIndex, file snippetFile) error {
+	filePath := path.Join(absPath, file.Filename)
+
+	err := os.MkdirAll(path.Dir(filePath), 0755)
 	if err != nil {
 		return err
 	}
 
-	index, err := repo.Index()
+	f, err := os.Create(filePath)
 	if err != nil {
 		return err
 	}
 
-	for _, file := range oldFiles {
-		filePath := path.Join(absPath, file.Filename)
-		err = os.Remove(filePath)
+	_, err = f.WriteString(file.Contents)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	return index.Add(file.Filename)
+}
+
+// repoRemoveFile will remove a file from the working directory of a repository,
+// along with any parent directories left empty, and remove it from the index
+func repoRemoveFile(absPath string, index *GitIndex, filename string) error {
+	err := os.Remove(path.Join(absPath, filename))
+	if err != nil {
+		return err
+	}
+
+	// Removing a directory fails once one that isn't empty is reached
+	for dir := path.Dir(filename); dir != "."; dir = path.Dir(dir) {
+		if os.Remove(path.Join(absPath, dir)) != nil {
+			break
+		}
+	}
+
+	return index.Rm(filename)
+}
+
+// repoDelete will permanently delete the repository from the filesystem
+func repoDelete(id string) error {
+	return os.RemoveAll(repoPath(id))
+}
+
+// repoTree will open the repository of a snippet and look up the tree of the
+// given revision, or HEAD if none is given
+func repoTree(id, rev string) (*GitTree, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+
+	repo, err := GitRepositoryOpen(repoPath(id))
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.RevparseTree(rev)
+}
+
+// repoReadFile will read a file of a snippet as it was at the given revision,
+// returning nil if there is no such revision or file
+func repoReadFile(id, rev, filename string) ([]byte, error) {
+	tree, err := repoTree(id, rev)
+	if err != nil || tree == nil {
+		return nil, err
+	}
+	defer tree.Free()
+
+	return tree.ReadFile(filename)
+}
+
+// repoReadFiles will read all the files of a snippet as they were at the given
+// revision, returning nil if there is no such revision
+func repoReadFiles(id, rev string) (snippetFiles, error) {
+	tree, err := repoTree(id, rev)
+	if err != nil || tree == nil {
+		return nil, err
+	}
+	defer tree.Free()
+
+	files := make(snippetFiles, 0)
+
+	filenames, err := tree.Files()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, filename := range filenames {
+		contents, err := tree.ReadFile(filename)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		err = index.Rm(file.Filename)
+		files = append(files, snippetFile{
+			SnippetID: id,
+			Filename:  filename,
+			Size:      int64(len(contents)),
+			Contents:  string(contents),
+		})
+	}
+
+	return files, nil
+}
+
+// repoHead will return the id of the latest revision of a snippet
+func repoHead(id string) (string, error) {
+	repo, err := GitRepositoryOpen(repoPath(id))
+	if err != nil {
+		return "", err
+	}
+
+	return repo.Head()
+}
+
+// repoResolve will return the full id of a revision of a snippet given by
+// any name git accepts for it, or an empty string if there is no such revision
+func repoResolve(id, rev string) (string, error) {
+	repo, err := GitRepositoryOpen(repoPath(id))
+	if err != nil {
+		return "", err
+	}
+
+	return repo.Resolve(rev)
+}
+
+// repoMergeBase will return the id of the latest revision of a snippet that
+// two revisions both descend from, or an empty string if there is none
+func repoMergeBase(id, one, two string) (string, error) {
+	repo, err := GitRepositoryOpen(repoPath(id))
+	if err != nil {
+		return "", err
+	}
+
+	return repo.MergeBase(one, two)
+}
+
+// repoHistory will list the revisions of a snippet, newest first
+func repoHistory(id string) ([]*GitCommit, error) {
+	repo, err := GitRepositoryOpen(repoPath(id))
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.Log(0)
+}
+
+// repoDiff will compare the files of a snippet at two revisions, with patches
+// for the text files that changed if patch is true. If from is empty the
+// parent of to is used, and the first revision is compared against nothing
+// at all. nil is returned if either revision doesn't exist
+func repoDiff(id, from, to string, patch bool) ([]snippetFileChange, error) {
+	if to == "" {
+		to = "HEAD"
+	}
+
+	to, err := repoResolve(id, to)
+	if err != nil || to == "" {
+		return nil, err
+	}
+
+	var oldFiles snippetFiles
+
+	if from == "" {
+		from, err = repoResolve(id, to+"~1")
+	} else {
+		from, err = repoResolve(id, from)
+		if err == nil && from == "" {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if from != "" {
+		oldFiles, err = repoReadFiles(id, from)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
+	newFiles, err := repoReadFiles(id, to)
+	if err != nil || newFiles == nil {
+		return nil, err
+	}
+
+	return repoChanges(oldFiles, newFiles, nil, patch), nil
+}
+
+// repoChanges will work out the changes that turn one set of files into
+// another. Renames may be given explicitly, from old to new filename, and the
+// rest are detected by comparing the contents of removed and added files
+func repoChanges(oldFiles, newFiles snippetFiles, renames map[string]string, patch bool) []snippetFileChange {
+	changes := make([]snippetFileChange, 0)
+
+	oldByName := make(map[string]*snippetFile)
+	for i := range oldFiles {
+		oldByName[oldFiles[i].Filename] = &oldFiles[i]
+	}
+
+	newByName := make(map[string]*snippetFile)
+	for i := range newFiles {
+		newByName[newFiles[i].Filename] = &newFiles[i]
+	}
+
+	renamedFrom := make(map[string]string)
+	for oldName, newName := range renames {
+		if oldByName[oldName] != nil && newByName[newName] != nil {
+			renamedFrom[newName] = oldName
+		}
+	}
+
+	for oldName, newName := range repoDetectRenames(oldFiles, newFiles, renamedFrom) {
+		renamedFrom[newName] = oldName
+	}
+
+	renamed := make(map[string]bool)
+	for _, oldName := range renamedFrom {
+		renamed[oldName] = true
+	}
+
 	for _, file := range newFiles {
-		var f *os.File
-		filePath := path.Join(absPath, file.Filename)
-		f, err = os.Create(filePath)
-		if err != nil {
-			return err
+		oldName, ok := renamedFrom[file.Filename]
+		if !ok {
+			oldName = file.Filename
 		}
-		_, err = f.WriteString(file.Contents)
-		if err != nil {
-			return err
+
+		oldFile := oldByName[oldName]
+
+		change := snippetFileChange{Filename: file.Filename}
+		switch {
+		case ok:
+			change.Status = CHANGE_RENAMED
+			change.OldFilename = oldName
+			change.Similarity = repoSimilarity(oldFile, &file)
+
+		case oldFile == nil || renamed[oldName]:
+			change.Status = CHANGE_ADDED
+			oldFile = nil
+
+		case oldFile.Contents != file.Contents:
+			change.Status = CHANGE_MODIFIED
+
+		default:
+			continue
 		}
 
-		err = index.Add(file.Filename)
-		if err != nil {
-			return err
+		change.Binary = file.Binary || IsBinary([]byte(file.Contents))
+
+		if patch && !change.Binary && (oldFile == nil || oldFile.Contents != file.Contents) {
+			var oldContents string
+			oldPath := "/dev/null"
+			if oldFile != nil {
+				oldContents = oldFile.Contents
+				oldPath = "a/" + oldFile.Filename
+			}
+
+			change.Patch = diffUnified(oldPath, "b/"+file.Filename, oldContents, file.Contents)
+		}
+
+		changes = append(changes, change)
+	}
+
+	for _, file := range oldFiles {
+		if newByName[file.Filename] == nil && !renamed[file.Filename] {
+			changes = append(changes, snippetFileChange{
+				Status:   CHANGE_REMOVED,
+				Filename: file.Filename,
+			})
 		}
 	}
 
-	return repo.Commit(u.DisplayName, u.Email)
+	return changes
 }
 
-// repoDelete will permanently delete the repository from the filesystem
-func repoDelete(id string) error {
-	return os.RemoveAll(repoPath(id))
+// repoDetectRenames will pair up files that were removed with files that were
+// added when their contents are similar enough, most similar first. Files
+// already known to be renamed, given as a map from new to old filename, are
+// left out. A map of old to new filenames is returned
+func repoDetectRenames(oldFiles, newFiles snippetFiles, renamedFrom map[string]string) map[string]string {
+	known := make(map[string]bool)
+	for newName, oldName := range renamedFrom {
+		known[newName] = true
+		known[oldName] = true
+	}
+
+	var removed, added []*snippetFile
+	for i := range oldFiles {
+		if !known[oldFiles[i].Filename] && !newFiles.contains(oldFiles[i].Filename) {
+			removed = append(removed, &oldFiles[i])
+		}
+	}
+	for i := range newFiles {
+		if !known[newFiles[i].Filename] && !oldFiles.contains(newFiles[i].Filename) {
+			added = append(added, &newFiles[i])
+		}
+	}
+
+	var candidates renameCandidates
+	for _, oldFile := range removed {
+		for _, newFile := range added {
+			similarity := repoSimilarity(oldFile, newFile)
+			if similarity >= RENAME_SIMILARITY_MIN {
+				candidates = append(candidates, renameCandidate{oldFile, newFile, similarity})
+			}
+		}
+	}
+
+	sort.Stable(candidates)
+
+	renames := make(map[string]string)
+	paired := make(map[*snippetFile]bool)
+	for _, c := range candidates {
+		if !paired[c.oldFile] && !paired[c.newFile] {
+			renames[c.oldFile.Filename] = c.newFile.Filename
+			paired[c.oldFile] = true
+			paired[c.newFile] = true
+		}
+	}
+
+	return renames
+}
+
+// repoSimilarity returns how alike the contents of two files are as a
+// percentage. Binary files are either identical or not alike at all
+func repoSimilarity(a, b *snippetFile) int {
+	if a.Contents == b.Contents {
+		return 100
+	}
+
+	if IsBinary([]byte(a.Contents)) || IsBinary([]byte(b.Contents)) {
+		return 0
+	}
+
+	return diffSimilarity(a.Contents, b.Contents)
 }
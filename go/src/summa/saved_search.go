@@ -0,0 +1,114 @@
+package summa
+
+import (
+	"database/sql"
+	_ "go-sqlite3"
+)
+
+type savedSearch struct {
+	ID       int64     `json:"id"`
+	Username string    `json:"-"`
+	Name     string    `json:"name"`
+	Term     string    `json:"term"`
+	Created  int64     `json:"created"`
+	Unread   *snippets `json:"unread,omitempty"`
+}
+
+type savedSearches []savedSearch
+
+// savedSearchIsOwnedBy returns true if the saved search with the given id is
+// owned by the given username
+func savedSearchIsOwnedBy(db *sql.DB, id, username string) (bool, error) {
+	var count int64
+	row := db.QueryRow(
+		"SELECT COUNT(*) FROM saved_search WHERE search_id=? AND username=?",
+		id,
+		username,
+	)
+	err := row.Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count == 1, nil
+}
+
+// savedSearchCreate will create a new saved search in the database
+func savedSearchCreate(db *sql.DB, search *savedSearch) error {
+	search.Created = UnixMilliseconds()
+
+	result, err := db.Exec(
+		"INSERT INTO saved_search VALUES (NULL,?,?,?,?)",
+		search.Username,
+		search.Name,
+		search.Term,
+		search.Created,
+	)
+	if err != nil {
+		return err
+	}
+
+	search.ID, err = result.LastInsertId()
+
+	return err
+}
+
+// savedSearchDelete permanently removes a saved search from the database
+func savedSearchDelete(db *sql.DB, id string) error {
+	_, err := db.Exec("DELETE FROM saved_search WHERE search_id=?", id)
+
+	return err
+}
+
+// savedSearchesFetch will fetch the saved searches for a specific user
+func savedSearchesFetch(db *sql.DB, username string) (savedSearches, error) {
+	var searches savedSearches
+
+	rows, err := db.Query(
+		"SELECT search_id,username,name,term,created FROM saved_search "+
+			"WHERE username=? ORDER BY name",
+		username,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var search savedSearch
+
+		rows.Scan(
+			&search.ID,
+			&search.Username,
+			&search.Name,
+			&search.Term,
+			&search.Created,
+		)
+
+		searches = append(searches, search)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return searches, nil
+}
+
+// savedSearchesFetchUnread will fetch the saved searches for a specific user,
+// along with the snippets matching each one that the user has not yet read
+func savedSearchesFetchUnread(db *sql.DB, username string) (savedSearches, error) {
+	searches, err := savedSearchesFetch(db, username)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range searches {
+		searches[i].Unread, err = snippetsSearchUnread(db, username, searches[i].Term)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return searches, nil
+}
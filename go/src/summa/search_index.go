@@ -0,0 +1,181 @@
+package summa
+
+import (
+	"database/sql"
+	_ "go-sqlite3"
+	"os"
+)
+
+// SearchIndexReport describes the differences found between the full text
+// search index and the snippets it should contain
+type SearchIndexReport struct {
+	Checked    int64    `json:"checked"`
+	Missing    []string `json:"missing"`
+	Stale      []string `json:"stale"`
+	Unreadable []string `json:"unreadable"`
+	Orphaned   []int64  `json:"orphaned"`
+	Repaired   bool     `json:"repaired"`
+}
+
+// VerifySearchIndex opens the Summa database and compares the full text
+// search index against the snippets and their git repositories, repairing
+// any differences found if repair is true
+func VerifySearchIndex(repair bool) (*SearchIndexReport, error) {
+	db, err := sql.Open("sqlite3", config.DBFile())
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return searchIndexVerify(db, repair)
+}
+
+// searchIndexVerify will rebuild the search document of every snippet from the
+// database and its repository and compare it to the indexed document. Each
+// repair is written separately so readers are never locked out for long
+func searchIndexVerify(db *sql.DB, repair bool) (*SearchIndexReport, error) {
+	var report SearchIndexReport
+	var ids []string
+
+	rows, err := db.Query("SELECT snippet_id FROM snippet ORDER BY created")
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		// A snippet whose files can't be read from its repository
+		// can't be indexed, but shouldn't stop the others
+		snip, err := snippetFetch(db, id)
+		if err == nil && snip == nil {
+			// Snippets in the trash stay indexed so
+			// they can be found once restored
+			snip, err = snippetFetchDeleted(db, id)
+		}
+
+		if searchIndexUnreadable(err) {
+			report.Unreadable = append(report.Unreadable, id)
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Deleted while we were working
+		if snip == nil {
+			continue
+		}
+
+		report.Checked++
+
+		for i, file := range snip.Files {
+			if file.Omitted && !file.Binary {
+				snip.Files[i].Contents, err = snippetFileRead(id, file.Filename)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		expected := snippetSearchDocument(snip.Description, snip.Files)
+
+		var indexed string
+		row := db.QueryRow(
+			"SELECT snippet FROM snippet_search WHERE docid=?",
+			snip.SearchID,
+		)
+		err = row.Scan(&indexed)
+
+		switch {
+		case err == sql.ErrNoRows:
+			report.Missing = append(report.Missing, id)
+			if repair {
+				_, err = db.Exec(
+					"INSERT INTO snippet_search (docid, snippet) VALUES (?, ?)",
+					snip.SearchID,
+					expected,
+				)
+			}
+
+		case err != nil:
+			return nil, err
+
+		case indexed != expected:
+			report.Stale = append(report.Stale, id)
+			if repair {
+				_, err = db.Exec(
+					"UPDATE snippet_search SET snippet=? WHERE docid=?",
+					expected,
+					snip.SearchID,
+				)
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err = db.Query(
+		"SELECT docid FROM snippet_search WHERE docid NOT IN (SELECT search_id FROM snippet)",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var docid int64
+		rows.Scan(&docid)
+		report.Orphaned = append(report.Orphaned, docid)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if !repair {
+		return &report, nil
+	}
+
+	for _, docid := range report.Orphaned {
+		_, err = db.Exec("DELETE FROM snippet_search WHERE docid=?", docid)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = db.Exec("INSERT INTO snippet_search (snippet_search) VALUES ('optimize')")
+	if err != nil {
+		return nil, err
+	}
+
+	report.Repaired = true
+
+	infoLog.Printf(
+		"Search index repaired: %d missing, %d stale, %d orphaned",
+		len(report.Missing),
+		len(report.Stale),
+		len(report.Orphaned),
+	)
+
+	return &report, nil
+}
+
+// searchIndexUnreadable returns true if err came from reading the files of a
+// snippet from its repository, rather than from the database
+func searchIndexUnreadable(err error) bool {
+	_, ok := err.(*os.PathError)
+	return ok
+}
@@ -3,44 +3,113 @@ package summa
 import (
 	"bytes"
 	"database/sql"
+	"errors"
 	_ "go-sqlite3"
 	"io/ioutil"
 	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 )
 
 const (
 	LANG_MARKDOWN = "Markdown"
+
+	SNIPPET_PATH_DEPTH_MAX = 8
+)
+
+var (
+	snippetPathSegmentRegex = regexp.MustCompile("(?i)^[a-z0-9_.-]+$")
+
+	// errEditConflict is returned when a snippet or comment has
+	// been changed since it was fetched to be updated
+	errEditConflict = errors.New("Changed since it was fetched")
 )
 
 type snippetFile struct {
 	SnippetID string `json:"-"`
 	Filename  string `json:"filename"`
 	Language  string `json:"language"`
+	Size      int64  `json:"size"`
+	MimeType  string `json:"mimeType"`
+	Binary    bool   `json:"binary,omitempty"`
+	Omitted   bool   `json:"omitted,omitempty"`
+	Keep      bool   `json:"-"`
 	Contents  string `json:"contents,omitempty"`
 	HTML      string `json:"html,omitempty"`
 }
 
 type snippetFiles []snippetFile
 
+func (f snippetFiles) Len() int           { return len(f) }
+func (f snippetFiles) Less(i, j int) bool { return f[i].Filename < f[j].Filename }
+func (f snippetFiles) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+
+// contains returns true if there is a file with the given filename
+func (f snippetFiles) contains(filename string) bool {
+	for _, file := range f {
+		if file.Filename == filename {
+			return true
+		}
+	}
+	return false
+}
+
+// snippetTreeNode is a file or directory in the tree
+// formed by the paths of a snippet's files
+type snippetTreeNode struct {
+	Name     string             `json:"name"`
+	Path     string             `json:"path"`
+	Dir      bool               `json:"dir,omitempty"`
+	Children []*snippetTreeNode `json:"children,omitempty"`
+}
+
+type snippetMatch struct {
+	Filename string `json:"filename"`
+	Lines    []int  `json:"lines"`
+}
+
+type snippetMatches []snippetMatch
+
 type snippet struct {
-	ID          string          `json:"id"`
-	SearchID    int64           `json:"-"`
-	Username    string          `json:"username"`
-	DisplayName string          `json:"displayName"`
-	Description string          `json:"description"`
-	Created     int64           `json:"created"`
-	Updated     int64           `json:"updated"`
-	Files       snippetFiles    `json:"files,omitempty"`
-	NumFiles    int64           `json:"numFiles"`
-	Comments    snippetComments `json:"comments,omitempty"`
-	NumComments int64           `json:"numComments"`
-	Revisions   []string        `json:"revisions,omitempty"`
+	ID              string               `json:"id"`
+	SearchID        int64                `json:"-"`
+	Username        string               `json:"username"`
+	DisplayName     string               `json:"displayName"`
+	Description     string               `json:"description"`
+	DescriptionHTML string               `json:"descriptionHtml,omitempty"`
+	Tags            []string             `json:"tags,omitempty"`
+	Created         int64                `json:"created"`
+	Updated         int64                `json:"updated"`
+	Revision        string               `json:"revision,omitempty"`
+	Files           snippetFiles         `json:"files,omitempty"`
+	Tree            []*snippetTreeNode   `json:"tree,omitempty"`
+	NumFiles        int64                `json:"numFiles"`
+	Comments        snippetComments      `json:"comments,omitempty"`
+	Review          snippetReviewThreads `json:"review,omitempty"`
+	Reactions       snippetReactions     `json:"reactions,omitempty"`
+	NumComments     int64                `json:"numComments"`
+	Revisions       []string             `json:"revisions,omitempty"`
+	Renames         map[string]string    `json:"-"`
+	Changes         []snippetFileChange  `json:"changes,omitempty"`
+	Matches         snippetMatches       `json:"matches,omitempty"`
+	Score           float64              `json:"score,omitempty"`
+	Template        bool                 `json:"template"`
+	ExpiresAt       int64                `json:"expiresAt,omitempty"`
+	Deleted         int64                `json:"deleted,omitempty"`
+	Archived        bool                 `json:"archived,omitempty"`
+	Locked          bool                 `json:"locked,omitempty"`
+	Authors         []snippetAuthor      `json:"authors,omitempty"`
+	Transfer        *snippetTransfer     `json:"transfer,omitempty"`
+	Variables       []string             `json:"variables,omitempty"`
 }
 
 // snippetExists checks is a snippet with the given ID exists
+// and hasn't been deleted
 func snippetExists(db *sql.DB, id string) (bool, error) {
 	var count int64
-	row := db.QueryRow("SELECT COUNT(*) FROM snippet WHERE snippet_id=?", id)
+	row := db.QueryRow("SELECT COUNT(*) FROM snippet WHERE snippet_id=? AND deleted=0", id)
 	err := row.Scan(&count)
 	if err != nil {
 		return false, err
@@ -56,7 +125,6 @@ func snippetExists(db *sql.DB, id string) (bool, error) {
 // snippetCreate will create a new snippet and return it's id
 func snippetCreate(db *sql.DB, snip *snippet, u *User) (string, error) {
 	var err error
-	var b bytes.Buffer
 
 	tx, err := db.Begin()
 	if err != nil {
@@ -74,13 +142,15 @@ func snippetCreate(db *sql.DB, snip *snippet, u *User) (string, error) {
 	ms := UnixMilliseconds()
 	var id string
 	for {
+		// Deleted snippets keep their ids until they are purged
+		var count int64
 		id = Reverse(ToBase36(ms))
-		exists, err := snippetExists(db, id)
+		err := db.QueryRow("SELECT COUNT(*) FROM snippet WHERE snippet_id=?", id).Scan(&count)
 		if err != nil {
 			return "", err
 		}
 
-		if !exists {
+		if count == 0 {
 			break
 		}
 
@@ -88,19 +158,20 @@ func snippetCreate(db *sql.DB, snip *snippet, u *User) (string, error) {
 	}
 
 	_, err = tx.Exec(
-		"INSERT INTO snippet VALUES (?,?,?,?,?,0)",
+		"INSERT INTO snippet (snippet_id,search_id,username,description,created,updated,template,expires) "+
+			"VALUES (?,?,?,?,?,0,?,?)",
 		id,
 		ms,
 		snip.Username,
 		snip.Description,
 		ms,
+		snip.Template,
+		snip.ExpiresAt,
 	)
 	if err != nil {
 		return "", err
 	}
 
-	b.WriteString(snip.Description + "\n")
-
 	for _, file := range snip.Files {
 		_, err = tx.Exec(
 			"INSERT INTO snippet_file VALUES (?,?,?)",
@@ -111,27 +182,53 @@ func snippetCreate(db *sql.DB, snip *snippet, u *User) (string, error) {
 		if err != nil {
 			return "", err
 		}
-
-		b.WriteString(file.Contents + "\n")
 	}
 
 	_, err = tx.Exec(
 		"INSERT INTO snippet_search (docid, snippet) VALUES (?, ?)",
 		ms,
-		b.String(),
+		snippetSearchDocument(snip.Description, snip.Files),
 	)
 	if err != nil {
 		return "", err
 	}
 
+	err = snippetTagsSet(tx, id, snip.Tags)
+	if err != nil {
+		return "", err
+	}
+
+	err = mentionNotify(tx, snip.Username, id, 0, snip.Description, "")
+	if err != nil {
+		return "", err
+	}
+
 	err = repoCreate(id, u, snip.Files)
+	if err != nil {
+		return "", err
+	}
 
 	return id, nil
 }
 
 func snippetUpdate(db *sql.DB, oldSnip, newSnip *snippet, u *User) error {
 	var err error
-	var b bytes.Buffer
+
+	// The owner and the co-authors all share in each revision
+	owner, err := userFetch(db, oldSnip.Username)
+	if err != nil {
+		return err
+	}
+
+	authors := oldSnip.Authors
+	if owner != nil {
+		ownerAuthor := snippetAuthor{
+			Username:    owner.Username,
+			DisplayName: owner.DisplayName,
+			Email:       owner.Email,
+		}
+		authors = append([]snippetAuthor{ownerAuthor}, authors...)
+	}
 
 	tx, err := db.Begin()
 	if err != nil {
@@ -146,20 +243,37 @@ func snippetUpdate(db *sql.DB, oldSnip, newSnip *snippet, u *User) error {
 		}
 	})()
 
+	// Nobody else may have updated the snippet since it was fetched
+	fetchedUpdated := oldSnip.Updated
+	oldDescription := oldSnip.Description
+
 	oldSnip.Updated = UnixMilliseconds()
 	oldSnip.Description = newSnip.Description
+	oldSnip.Template = newSnip.Template
+	oldSnip.ExpiresAt = newSnip.ExpiresAt
 
-	_, err = tx.Exec(
-		"UPDATE snippet SET description=?,updated=? WHERE snippet_id=?",
+	res, err := tx.Exec(
+		"UPDATE snippet SET description=?,updated=?,template=?,expires=? WHERE snippet_id=? AND updated=?",
 		oldSnip.Description,
 		oldSnip.Updated,
+		oldSnip.Template,
+		oldSnip.ExpiresAt,
 		oldSnip.ID,
+		fetchedUpdated,
 	)
 	if err != nil {
 		return err
 	}
 
-	b.WriteString(newSnip.Description + "\n")
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		err = errEditConflict
+		return err
+	}
 
 	_, err = tx.Exec("DELETE FROM snippet_file WHERE snippet_id=?", oldSnip.ID)
 	if err != nil {
@@ -176,26 +290,138 @@ func snippetUpdate(db *sql.DB, oldSnip, newSnip *snippet, u *User) error {
 		if err != nil {
 			return err
 		}
-
-		b.WriteString(file.Contents + "\n")
 	}
 
 	_, err = tx.Exec(
 		"UPDATE snippet_search SET snippet=? WHERE docid=?",
-		b.String(),
+		snippetSearchDocument(newSnip.Description, newSnip.Files),
 		oldSnip.SearchID,
 	)
 	if err != nil {
 		return err
 	}
 
-	err = repoUpdate(oldSnip.ID, u, oldSnip.Files, newSnip.Files)
+	err = snippetTagsSet(tx, oldSnip.ID, newSnip.Tags)
+	if err != nil {
+		return err
+	}
+
+	err = mentionNotify(tx, u.Username, oldSnip.ID, 0, newSnip.Description, oldDescription)
+	if err != nil {
+		return err
+	}
+
+	changes, err := repoUpdate(oldSnip.ID, u, newSnip.Files, newSnip.Renames, repoCommitMessage(u, authors))
+	if err != nil {
+		return err
+	}
 
 	oldSnip.Files = newSnip.Files
+	oldSnip.Tree = snippetFilesTree(newSnip.Files)
+	oldSnip.Tags = newSnip.Tags
+	oldSnip.Changes = changes
+
+	oldSnip.Revision, err = repoHead(oldSnip.ID)
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// snippetFilenameIsValid returns true if a filename is a safe relative path
+// within a repository, made up of simple names separated by slashes. Paths
+// that could step outside of the repository or into its .git directory
+// are rejected
+func snippetFilenameIsValid(filename string) bool {
+	segments := strings.Split(filename, "/")
+	if len(segments) > SNIPPET_PATH_DEPTH_MAX {
+		return false
+	}
+
+	for _, segment := range segments {
+		switch {
+		case !snippetPathSegmentRegex.MatchString(segment):
+			return false
+		case segment == "." || segment == "..":
+			return false
+		case strings.ToLower(segment) == ".git":
+			return false
+		}
+	}
+
+	return true
+}
+
+// snippetFilesTree arranges the files of a snippet into a tree of directories
+// and files, with the directories of each level listed before its files
+func snippetFilesTree(files snippetFiles) []*snippetTreeNode {
+	root := &snippetTreeNode{Dir: true}
+	dirs := map[string]*snippetTreeNode{"": root}
+
+	sorted := make(snippetFiles, len(files))
+	copy(sorted, files)
+	sort.Sort(sorted)
+
+	var parentOf func(dir string) *snippetTreeNode
+	parentOf = func(dir string) *snippetTreeNode {
+		if dir == "." {
+			dir = ""
+		}
+
+		if node, ok := dirs[dir]; ok {
+			return node
+		}
+
+		parent := parentOf(path.Dir(dir))
+		node := &snippetTreeNode{Name: path.Base(dir), Path: dir, Dir: true}
+		parent.Children = append(parent.Children, node)
+		dirs[dir] = node
+
+		return node
+	}
+
+	for _, file := range sorted {
+		parent := parentOf(path.Dir(file.Filename))
+		parent.Children = append(parent.Children, &snippetTreeNode{
+			Name: path.Base(file.Filename),
+			Path: file.Filename,
+		})
+	}
+
+	for _, node := range dirs {
+		sort.Stable(snippetTreeNodes(node.Children))
+	}
+
+	return root.Children
+}
+
+type snippetTreeNodes []*snippetTreeNode
+
+func (n snippetTreeNodes) Len() int           { return len(n) }
+func (n snippetTreeNodes) Less(i, j int) bool { return n[i].Dir && !n[j].Dir }
+func (n snippetTreeNodes) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
+
+// snippetSearchDocument returns the text stored in the full text search index
+// for a snippet, made up of its description and the contents of its files
+// in filename order
+func snippetSearchDocument(description string, files snippetFiles) string {
+	var b bytes.Buffer
+
+	sorted := make(snippetFiles, len(files))
+	copy(sorted, files)
+	sort.Sort(sorted)
+
+	b.WriteString(description + "\n")
+	for _, file := range sorted {
+		if !file.Binary {
+			b.WriteString(file.Contents + "\n")
+		}
+	}
+
+	return b.String()
+}
+
 // snippetMarkReadBy will mark a snippet with a specified id as read
 // by a specific user
 func snippetMarkReadBy(db *sql.DB, id, username string) error {
@@ -229,13 +455,48 @@ func snippetMarkUnreadBy(db *sql.DB, id, username string) error {
 	return err
 }
 
-// snippetDelete permanently removes a snippet
+// snippetDelete will move a snippet to the trash, where it stays until it
+// is restored or purged
 func snippetDelete(db *sql.DB, id string) error {
+	_, err := db.Exec(
+		"UPDATE snippet SET deleted=? WHERE snippet_id=? AND deleted=0",
+		UnixMilliseconds(),
+		id,
+	)
+
+	return err
+}
+
+// snippetRestore will take a snippet back out of the trash. A snippet that was
+// deleted because it expired no longer expires, or it would be deleted again
+func snippetRestore(db *sql.DB, id string) error {
+	_, err := db.Exec(
+		"UPDATE snippet SET deleted=0,expires=CASE WHEN expires<=? THEN 0 ELSE expires END "+
+			"WHERE snippet_id=?",
+		UnixMilliseconds(),
+		id,
+	)
+
+	return err
+}
+
+// snippetPurge will permanently delete a snippet, along with its
+// comments, views and repository
+func snippetPurge(db *sql.DB, id string) error {
 	queries := []string{
 		"DELETE FROM snippet WHERE snippet_id=?",
+		"DELETE FROM snippet_comment_revision WHERE comment_id IN " +
+			"(SELECT comment_id FROM snippet_comment WHERE snippet_id=?)",
 		"DELETE FROM snippet_comment WHERE snippet_id=?",
+		"DELETE FROM snippet_comment_anchor WHERE snippet_id=?",
 		"DELETE FROM snippet_file WHERE snippet_id=?",
 		"DELETE FROM snippet_view WHERE snippet_id=?",
+		"DELETE FROM snippet_thread_view WHERE snippet_id=?",
+		"DELETE FROM snippet_tag WHERE snippet_id=?",
+		"DELETE FROM snippet_author WHERE snippet_id=?",
+		"DELETE FROM snippet_transfer WHERE snippet_id=?",
+		"DELETE FROM snippet_reaction WHERE snippet_id=?",
+		"DELETE FROM user_notification WHERE snippet_id=?",
 	}
 
 	tx, err := db.Begin()
@@ -251,17 +512,57 @@ func snippetDelete(db *sql.DB, id string) error {
 		}
 	}
 
-	searchId, _ := FromBase36(Reverse(id))
+	searchId, err := FromBase36(Reverse(id))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	_, err = tx.Exec(
 		"DELETE FROM snippet_search WHERE docid=?",
 		searchId,
 	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
 
-	tx.Commit()
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
 
-	repoDelete(id)
+	return repoDelete(id)
+}
 
-	return nil
+// snippetSetArchived will archive a snippet, or bring it back out of the archive
+func snippetSetArchived(db *sql.DB, id string, archived bool) error {
+	_, err := db.Exec("UPDATE snippet SET archived=? WHERE snippet_id=?", archived, id)
+
+	return err
+}
+
+// snippetSetLocked will lock or unlock a snippet
+func snippetSetLocked(db *sql.DB, id string, locked bool) error {
+	_, err := db.Exec("UPDATE snippet SET locked=? WHERE snippet_id=?", locked, id)
+
+	return err
+}
+
+// snippetIsReadOnly returns true if the snippet with the given id is archived
+// or locked, so neither it nor its comments may be changed
+func snippetIsReadOnly(db *sql.DB, id string) (bool, error) {
+	var count int64
+	row := db.QueryRow(
+		"SELECT COUNT(*) FROM snippet WHERE snippet_id=? AND (archived=1 OR locked=1)",
+		id,
+	)
+	err := row.Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count == 1, nil
 }
 
 // snippetIsOwnedBy returns true if the snippet with the given id is
@@ -269,7 +570,7 @@ func snippetDelete(db *sql.DB, id string) error {
 func snippetIsOwnedBy(db *sql.DB, id, username string) (bool, error) {
 	var count int64
 	row := db.QueryRow(
-		"SELECT COUNT(*) FROM snippet WHERE snippet_id=? AND username=?",
+		"SELECT COUNT(*) FROM snippet WHERE snippet_id=? AND username=? AND deleted=0",
 		id,
 		username,
 	)
@@ -281,13 +582,25 @@ func snippetIsOwnedBy(db *sql.DB, id, username string) (bool, error) {
 	return count == 1, nil
 }
 
-// snippetFetch will fetch an individual snippet by ID
+// snippetFetch will fetch an individual snippet by ID,
+// unless it has been deleted
 func snippetFetch(db *sql.DB, id string) (*snippet, error) {
+	return snippetFetchWhere(db, id, "deleted=0")
+}
+
+// snippetFetchDeleted will fetch an individual snippet
+// by ID if it is in the trash
+func snippetFetchDeleted(db *sql.DB, id string) (*snippet, error) {
+	return snippetFetchWhere(db, id, "deleted>0")
+}
+
+func snippetFetchWhere(db *sql.DB, id, cond string) (*snippet, error) {
 	var snip snippet
 
 	row := db.QueryRow(
-		"SELECT snippet_id,search_id,username,display_name,description,created,updated "+
-			"FROM snippet JOIN user USING (username) WHERE snippet_id=?",
+		"SELECT snippet_id,search_id,username,display_name,description,created,updated,template,expires,deleted,"+
+			"archived,locked "+
+			"FROM snippet JOIN user USING (username) WHERE snippet_id=? AND "+cond,
 		id,
 	)
 
@@ -299,6 +612,11 @@ func snippetFetch(db *sql.DB, id string) (*snippet, error) {
 		&snip.Description,
 		&snip.Created,
 		&snip.Updated,
+		&snip.Template,
+		&snip.ExpiresAt,
+		&snip.Deleted,
+		&snip.Archived,
+		&snip.Locked,
 	)
 
 	switch {
@@ -313,10 +631,36 @@ func snippetFetch(db *sql.DB, id string) (*snippet, error) {
 		return nil, err
 	}
 
+	snip.Tags, err = snippetTagsFetch(db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	snip.Authors, err = snippetAuthorsFetch(db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	snip.Reactions, err = snippetReactionsFetch(db, id, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	mentioned, err := mentionUsers(db, mentionParse(snip.Description))
+	if err != nil {
+		return nil, err
+	}
+
+	snip.DescriptionHTML = mentionLinkText(snip.Description, mentioned)
+
+	snip.Tree = snippetFilesTree(snip.Files)
+
 	return &snip, nil
 }
 
 // snippetFetch will fetch an individual snippet by ID, including it's comments
+// and the revision of its repository. Comments made on lines of its files are
+// grouped by file and line apart from the rest
 func snippetFetchAll(db *sql.DB, id string) (*snippet, error) {
 	snip, err := snippetFetch(db, id)
 	if err != nil {
@@ -327,7 +671,17 @@ func snippetFetchAll(db *sql.DB, id string) (*snippet, error) {
 		return nil, nil
 	}
 
-	snip.Comments, err = snippetFetchComments(db, id)
+	snip.Revision, err = repoHead(id)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := snippetFetchComments(db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	snip.Comments, snip.Review, err = snippetReviewThreadsFetch(db, id, comments)
 	if err != nil {
 		return nil, err
 	}
@@ -335,12 +689,13 @@ func snippetFetchAll(db *sql.DB, id string) (*snippet, error) {
 	return snip, nil
 }
 
-// snippetFetchComments will fetch the comments for a specific snippet
+// snippetFetchComments will fetch the comments for a specific snippet as
+// threads, each holding its replies, oldest first
 func snippetFetchComments(db *sql.DB, id string) (snippetComments, error) {
 	var comments snippetComments
 
 	rows, err := db.Query(
-		"SELECT comment_id,username,display_name,markdown,html,created,updated FROM "+
+		"SELECT comment_id,parent_id,username,display_name,markdown,html,created,updated,deleted FROM "+
 			"snippet_comment JOIN user USING (username) WHERE snippet_id=? ORDER BY created",
 		id,
 	)
@@ -354,12 +709,14 @@ func snippetFetchComments(db *sql.DB, id string) (snippetComments, error) {
 
 		rows.Scan(
 			&comment.ID,
+			&comment.ParentID,
 			&comment.Username,
 			&comment.DisplayName,
 			&comment.Markdown,
 			&comment.HTML,
 			&comment.Created,
 			&comment.Updated,
+			&comment.Deleted,
 		)
 
 		comments = append(comments, comment)
@@ -369,10 +726,20 @@ func snippetFetchComments(db *sql.DB, id string) (snippetComments, error) {
 		return nil, err
 	}
 
-	return comments, nil
+	reactions, err := snippetCommentReactionsFetch(db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range comments {
+		comments[i].Reactions = reactions[comments[i].ID]
+	}
+
+	return snippetCommentThreads(comments), nil
 }
 
-// snippetFetchFiles will fetch the files for a sepcific snippet
+// snippetFetchFiles will fetch the files for a sepcific snippet. The contents of
+// binary files and files too large to be sent inline are omitted
 func snippetFetchFiles(db *sql.DB, id string) (snippetFiles, error) {
 	var files snippetFiles
 
@@ -397,6 +764,17 @@ func snippetFetchFiles(db *sql.DB, id string) (snippetFiles, error) {
 		)
 
 		filePath := path.Join(fsPath, file.Filename)
+		_, err = fileSniff(filePath, &file)
+		if err != nil {
+			return nil, err
+		}
+
+		if file.Binary || file.Size > config.MaxInlineFileSize {
+			file.Omitted = true
+			files = append(files, file)
+			continue
+		}
+
 		contents, err := ioutil.ReadFile(filePath)
 		if err != nil {
 			return nil, err
@@ -417,3 +795,99 @@ func snippetFetchFiles(db *sql.DB, id string) (snippetFiles, error) {
 
 	return files, nil
 }
+
+// snippetGrepFiles will scan the files of a specific snippet for lines matching
+// the given regular expression, giving up on the remaining files once the
+// deadline has passed, in which case the returned bool will be true
+func snippetGrepFiles(db *sql.DB, id string, re *regexp.Regexp, deadline time.Time) (snippetMatches, bool, error) {
+	var matches snippetMatches
+	var filenames []string
+
+	rows, err := db.Query(
+		"SELECT filename FROM snippet_file WHERE snippet_id=? ORDER BY filename",
+		id,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var filename string
+		rows.Scan(&filename)
+		filenames = append(filenames, filename)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	fsPath := repoPath(id)
+
+	for _, filename := range filenames {
+		if time.Now().After(deadline) {
+			return matches, true, nil
+		}
+
+		contents, err := ioutil.ReadFile(path.Join(fsPath, filename))
+		if err != nil {
+			return nil, false, err
+		}
+
+		if IsBinary(contents) {
+			continue
+		}
+
+		var lines []int
+		for i, line := range strings.Split(string(contents), "\n") {
+			if re.MatchString(line) {
+				lines = append(lines, i+1)
+			}
+		}
+
+		if len(lines) > 0 {
+			matches = append(matches, snippetMatch{filename, lines})
+		}
+	}
+
+	return matches, false, nil
+}
+
+// snippetFileRead will read the full contents of a file of a specific
+// snippet, including files omitted by snippetFetchFiles
+func snippetFileRead(id, filename string) (string, error) {
+	contents, err := ioutil.ReadFile(path.Join(repoPath(id), filename))
+	if err != nil {
+		return "", err
+	}
+
+	return string(contents), nil
+}
+
+// snippetFetchFile will fetch the details of a single file of a specific snippet,
+// without its contents
+func snippetFetchFile(db *sql.DB, id, filename string) (*snippetFile, error) {
+	var file snippetFile
+
+	row := db.QueryRow(
+		"SELECT snippet_id,filename,language FROM snippet_file JOIN snippet USING (snippet_id) "+
+			"WHERE snippet_id=? AND filename=? AND deleted=0",
+		id,
+		filename,
+	)
+
+	err := row.Scan(
+		&file.SnippetID,
+		&file.Filename,
+		&file.Language,
+	)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	return &file, nil
+}
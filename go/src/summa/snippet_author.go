@@ -0,0 +1,126 @@
+package summa
+
+import (
+	"database/sql"
+	_ "go-sqlite3"
+)
+
+// snippetAuthor is a user other than the owner who may edit a snippet
+type snippetAuthor struct {
+	Username    string `json:"username"`
+	DisplayName string `json:"displayName"`
+	Email       string `json:"-"`
+}
+
+// snippetAuthorsFetch will fetch the co-authors of a specific snippet
+func snippetAuthorsFetch(db *sql.DB, id string) ([]snippetAuthor, error) {
+	var authors []snippetAuthor
+
+	rows, err := db.Query(
+		"SELECT username,display_name,email FROM snippet_author JOIN user USING (username) "+
+			"WHERE snippet_id=? ORDER BY created",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var author snippetAuthor
+
+		rows.Scan(
+			&author.Username,
+			&author.DisplayName,
+			&author.Email,
+		)
+
+		authors = append(authors, author)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return authors, nil
+}
+
+// snippetsAuthorsFetch will fetch the co-authors of each of a list of
+// snippets, by snippet id
+func snippetsAuthorsFetch(db *sql.DB, ids []string) (map[string][]snippetAuthor, error) {
+	authors := make(map[string][]snippetAuthor)
+
+	for _, params := range sqlChunks(ids) {
+		rows, err := db.Query(
+			"SELECT snippet_id,username,display_name,email FROM snippet_author JOIN user USING (username) "+
+				"WHERE snippet_id IN ("+sqlPlaceholders(len(params))+") ORDER BY created",
+			params...,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var id string
+			var author snippetAuthor
+
+			rows.Scan(
+				&id,
+				&author.Username,
+				&author.DisplayName,
+				&author.Email,
+			)
+
+			authors[id] = append(authors[id], author)
+		}
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return authors, nil
+}
+
+// snippetAuthorAdd will make a user a co-author of a snippet
+func snippetAuthorAdd(db *sql.DB, id, username string) error {
+	_, err := db.Exec(
+		"INSERT OR IGNORE INTO snippet_author VALUES (?,?,?)",
+		id,
+		username,
+		UnixMilliseconds(),
+	)
+
+	return err
+}
+
+// snippetAuthorRemove will take a user off the co-authors of a snippet
+func snippetAuthorRemove(db *sql.DB, id, username string) error {
+	_, err := db.Exec(
+		"DELETE FROM snippet_author WHERE snippet_id=? AND username=?",
+		id,
+		username,
+	)
+
+	return err
+}
+
+// snippetCanEdit returns true if the snippet with the given id
+// is owned or co-authored by the given username
+func snippetCanEdit(db *sql.DB, id, username string) (bool, error) {
+	var count int64
+	row := db.QueryRow(
+		"SELECT COUNT(*) FROM snippet WHERE snippet_id=? AND deleted=0 AND (username=? OR "+
+			"snippet_id IN (SELECT snippet_id FROM snippet_author WHERE username=?))",
+		id,
+		username,
+		username,
+	)
+	err := row.Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count == 1, nil
+}
@@ -6,18 +6,69 @@ import (
 )
 
 type snippetComment struct {
-	ID          int64  `json:"id"`
-	SnippetID   string `json:"-"`
-	Username    string `json:"username"`
-	DisplayName string `json:"displayName"`
-	Markdown    string `json:"markdown"`
-	HTML        string `json:"html"`
-	Created     int64  `json:"created"`
-	Updated     int64  `json:"updated"`
+	ID          int64                 `json:"id"`
+	SnippetID   string                `json:"-"`
+	ParentID    int64                 `json:"parentId,omitempty"`
+	Username    string                `json:"username"`
+	DisplayName string                `json:"displayName"`
+	Markdown    string                `json:"markdown"`
+	HTML        string                `json:"html"`
+	Created     int64                 `json:"created"`
+	Updated     int64                 `json:"updated"`
+	Deleted     int64                 `json:"deleted,omitempty"`
+	Unread      bool                  `json:"unread,omitempty"`
+	Anchor      *snippetCommentAnchor `json:"anchor,omitempty"`
+	Reactions   snippetReactions      `json:"reactions,omitempty"`
+	Replies     snippetComments       `json:"replies,omitempty"`
 }
 
 type snippetComments []snippetComment
 
+// snippetCommentRevision is a version of a comment that has since been edited
+type snippetCommentRevision struct {
+	Markdown string `json:"markdown"`
+	HTML     string `json:"html"`
+	Created  int64  `json:"created"`
+	Replaced int64  `json:"replaced"`
+}
+
+// ThreadID returns the id of the comment that started the thread a comment
+// belongs to. Replies are always made to the first comment of a thread
+func (c *snippetComment) ThreadID() int64 {
+	if c.ParentID != 0 {
+		return c.ParentID
+	}
+	return c.ID
+}
+
+// snippetCommentThreads will gather replies under the comments starting their
+// threads. Replies whose thread can't be found are treated as threads of their own
+func snippetCommentThreads(comments snippetComments) snippetComments {
+	threads := make(snippetComments, 0)
+
+	started := make(map[int64]bool)
+	for _, comment := range comments {
+		if comment.ParentID == 0 {
+			started[comment.ID] = true
+		}
+	}
+
+	replies := make(map[int64]snippetComments)
+	for _, comment := range comments {
+		if started[comment.ParentID] {
+			replies[comment.ParentID] = append(replies[comment.ParentID], comment)
+		} else {
+			threads = append(threads, comment)
+		}
+	}
+
+	for i := range threads {
+		threads[i].Replies = replies[threads[i].ID]
+	}
+
+	return threads
+}
+
 // snippetCommentExists returns true if the comment with the given id exists in the database
 func snippetCommentExists(db *sql.DB, id string) (bool, error) {
 	var count int64
@@ -55,7 +106,7 @@ func snippetCommentFetch(db *sql.DB, id string) (*snippetComment, error) {
 	var comment snippetComment
 
 	row := db.QueryRow(
-		"SELECT comment_id,snippet_id,username,display_name,markdown,html,created,updated "+
+		"SELECT comment_id,snippet_id,parent_id,username,display_name,markdown,html,created,updated,deleted "+
 			"FROM snippet_comment JOIN user USING (username) WHERE comment_id=?",
 		id,
 	)
@@ -63,12 +114,14 @@ func snippetCommentFetch(db *sql.DB, id string) (*snippetComment, error) {
 	err := row.Scan(
 		&comment.ID,
 		&comment.SnippetID,
+		&comment.ParentID,
 		&comment.Username,
 		&comment.DisplayName,
 		&comment.Markdown,
 		&comment.HTML,
 		&comment.Created,
 		&comment.Updated,
+		&comment.Deleted,
 	)
 
 	switch {
@@ -91,15 +144,22 @@ func snippetCommentCreate(db *sql.DB, comment *snippetComment) error {
 	}
 
 	comment.Created = UnixMilliseconds()
-	comment.HTML = markdownParse(comment.Markdown)
 
-	result, err := db.Exec(
-		"INSERT INTO snippet_comment VALUES (NULL,?,?,?,?,?,0)",
+	comment.HTML, err = snippetCommentRender(tx, comment.Markdown)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO snippet_comment (snippet_id,username,markdown,html,created,updated,parent_id,deleted) "+
+			"VALUES (?,?,?,?,?,0,?,0)",
 		comment.SnippetID,
 		comment.Username,
 		comment.Markdown,
 		comment.HTML,
 		comment.Created,
+		comment.ParentID,
 	)
 	if err != nil {
 		tx.Rollback()
@@ -112,12 +172,38 @@ func snippetCommentCreate(db *sql.DB, comment *snippetComment) error {
 		return err
 	}
 
+	if comment.Anchor != nil {
+		err = snippetCommentAnchorCreate(tx, comment)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = mentionNotify(tx, comment.Username, comment.SnippetID, comment.ID, comment.Markdown, "")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	tx.Commit()
 
 	return nil
 }
 
-// snippetCommentUpdate will update an existing comment in the database
+// snippetCommentRender will turn the markdown of a comment into HTML, with
+// the users it mentions linked to their profiles
+func snippetCommentRender(tx *sql.Tx, markdown string) (string, error) {
+	users, err := mentionUsers(tx, mentionParse(markdown))
+	if err != nil {
+		return "", err
+	}
+
+	return mentionLinkHTML(markdownParse(markdown), users), nil
+}
+
+// snippetCommentUpdate will update an existing comment in the database,
+// keeping the version it replaces in the comment's history
 func snippetCommentUpdate(db *sql.DB, comment *snippetComment) error {
 	var err error
 
@@ -126,44 +212,210 @@ func snippetCommentUpdate(db *sql.DB, comment *snippetComment) error {
 		return err
 	}
 
+	// Nobody else may have updated the comment since it was fetched
+	fetchedUpdated := comment.Updated
+
 	comment.Updated = UnixMilliseconds()
 
-	_, err = db.Exec(
-		"UPDATE snippet_comment SET markdown=?,html=?,updated=? WHERE comment_id=?",
+	comment.HTML, err = snippetCommentRender(tx, comment.Markdown)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Only users who weren't mentioned before are notified
+	var oldMarkdown string
+	err = tx.QueryRow("SELECT markdown FROM snippet_comment WHERE comment_id=?", comment.ID).Scan(&oldMarkdown)
+	if err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO snippet_comment_revision (comment_id,markdown,html,created,replaced) "+
+			"SELECT comment_id,markdown,html,CASE WHEN updated=0 THEN created ELSE updated END,? "+
+			"FROM snippet_comment WHERE comment_id=? AND updated=?",
+		comment.Updated,
+		comment.ID,
+		fetchedUpdated,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	res, err := tx.Exec(
+		"UPDATE snippet_comment SET markdown=?,html=?,updated=? WHERE comment_id=? AND updated=?",
 		comment.Markdown,
-		markdownParse(comment.Markdown),
+		comment.HTML,
 		comment.Updated,
 		comment.ID,
+		fetchedUpdated,
 	)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
 
+	affected, err := res.RowsAffected()
+	if err != nil || affected == 0 {
+		tx.Rollback()
+		if err == nil {
+			err = errEditConflict
+		}
+		return err
+	}
+
+	err = mentionNotify(tx, comment.Username, comment.SnippetID, comment.ID, comment.Markdown, oldMarkdown)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	tx.Commit()
 
 	return nil
 }
 
-// snippetCommentDelete permanently removes a comment from the database
+// snippetCommentDelete replaces a comment with a tombstone, so that the
+// thread it belongs to keeps its shape. What the comment said is removed,
+// along with its history, reactions and the notifications it caused
 func snippetCommentDelete(db *sql.DB, id string) error {
-	queries := []string{
-		"DELETE FROM snippet_comment WHERE comment_id=?",
+	tx, err := db.Begin()
+	if err != nil {
+		return err
 	}
 
-	tx, err := db.Begin()
+	_, err = tx.Exec(
+		"UPDATE snippet_comment SET markdown='',html='',deleted=? WHERE comment_id=? AND deleted=0",
+		UnixMilliseconds(),
+		id,
+	)
 	if err != nil {
+		tx.Rollback()
 		return err
 	}
 
-	for _, q := range queries {
-		_, err = tx.Exec(q, id)
-		if err != nil {
-			tx.Rollback()
-			return err
-		}
+	_, err = tx.Exec("DELETE FROM snippet_comment_revision WHERE comment_id=?", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM snippet_reaction WHERE comment_id=?", id)
+	if err != nil {
+		tx.Rollback()
+		return err
 	}
+
+	_, err = tx.Exec("DELETE FROM user_notification WHERE comment_id=?", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	tx.Commit()
 
 	return nil
 }
+
+// snippetCommentHistory will fetch the earlier versions of a comment,
+// newest first
+func snippetCommentHistory(db *sql.DB, id string) ([]snippetCommentRevision, error) {
+	history := make([]snippetCommentRevision, 0)
+
+	rows, err := db.Query(
+		"SELECT markdown,html,created,replaced FROM snippet_comment_revision "+
+			"WHERE comment_id=? ORDER BY replaced DESC",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rev snippetCommentRevision
+
+		rows.Scan(
+			&rev.Markdown,
+			&rev.HTML,
+			&rev.Created,
+			&rev.Replaced,
+		)
+
+		history = append(history, rev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// snippetThreadMarkReadBy will mark a thread of comments on a snippet as read
+// by a specific user
+func snippetThreadMarkReadBy(db *sql.DB, snippetID string, threadID int64, username string) error {
+	_, err := db.Exec(
+		"REPLACE INTO snippet_thread_view VALUES (?,?,?)",
+		snippetID,
+		threadID,
+		username,
+	)
+
+	return err
+}
+
+// snippetThreadMarkUnread will mark a thread of comments as unread by all users
+func snippetThreadMarkUnread(db *sql.DB, threadID int64) error {
+	_, err := db.Exec(
+		"DELETE FROM snippet_thread_view WHERE comment_id=?",
+		threadID,
+	)
+
+	return err
+}
+
+// snippetThreadsMarkReadBy will mark every thread of comments on a snippet as
+// read by a specific user
+func snippetThreadsMarkReadBy(db *sql.DB, snippetID, username string) error {
+	_, err := db.Exec(
+		"REPLACE INTO snippet_thread_view SELECT snippet_id,comment_id,? FROM snippet_comment "+
+			"WHERE snippet_id=? AND parent_id=0",
+		username,
+		snippetID,
+	)
+
+	return err
+}
+
+// snippetThreadsUnread returns the ids of the threads of comments on a snippet
+// that have changed since a specific user last read them
+func snippetThreadsUnread(db *sql.DB, snippetID, username string) (map[int64]bool, error) {
+	unread := make(map[int64]bool)
+
+	rows, err := db.Query(
+		"SELECT sc.comment_id FROM snippet_comment sc LEFT JOIN snippet_thread_view tv ON "+
+			"sc.comment_id=tv.comment_id AND tv.username=? WHERE sc.snippet_id=? AND sc.parent_id=0 "+
+			"AND tv.username IS NULL",
+		username,
+		snippetID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		rows.Scan(&id)
+		unread[id] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return unread, nil
+}
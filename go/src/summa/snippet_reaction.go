@@ -0,0 +1,158 @@
+package summa
+
+import (
+	"database/sql"
+	_ "go-sqlite3"
+)
+
+const (
+	REACTION_UPVOTE   = "+1"
+	REACTION_DOWNVOTE = "-1"
+)
+
+var (
+	// The reactions that can be left on snippets and comments
+	snippetReactionNames = map[string]bool{
+		REACTION_UPVOTE:   true,
+		REACTION_DOWNVOTE: true,
+		"laugh":           true,
+		"hooray":          true,
+		"confused":        true,
+		"heart":           true,
+		"rocket":          true,
+		"eyes":            true,
+	}
+)
+
+// snippetReactions counts how many users left each reaction
+type snippetReactions map[string]int64
+
+// snippetReactionAdd will record a user's reaction to a snippet, or to one of
+// its comments if a comment id is given
+func snippetReactionAdd(db *sql.DB, snippetID string, commentID int64, username, reaction string) error {
+	_, err := db.Exec(
+		"INSERT OR IGNORE INTO snippet_reaction VALUES (?,?,?,?,?)",
+		snippetID,
+		commentID,
+		username,
+		reaction,
+		UnixMilliseconds(),
+	)
+
+	return err
+}
+
+// snippetReactionRemove will take back a user's reaction to a snippet, or to
+// one of its comments if a comment id is given
+func snippetReactionRemove(db *sql.DB, snippetID string, commentID int64, username, reaction string) error {
+	_, err := db.Exec(
+		"DELETE FROM snippet_reaction WHERE snippet_id=? AND comment_id=? AND username=? AND reaction=?",
+		snippetID,
+		commentID,
+		username,
+		reaction,
+	)
+
+	return err
+}
+
+// snippetReactionsFetch will count the reactions to a snippet, or to one of
+// its comments if a comment id is given
+func snippetReactionsFetch(db *sql.DB, snippetID string, commentID int64) (snippetReactions, error) {
+	reactions := make(snippetReactions)
+
+	rows, err := db.Query(
+		"SELECT reaction,COUNT(*) FROM snippet_reaction WHERE snippet_id=? AND comment_id=? "+
+			"GROUP BY reaction",
+		snippetID,
+		commentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reaction string
+		var count int64
+
+		rows.Scan(&reaction, &count)
+		reactions[reaction] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reactions, nil
+}
+
+// snippetsReactionsFetch will count the reactions to each of a list of
+// snippets, leaving out those to their comments, by snippet id
+func snippetsReactionsFetch(db *sql.DB, ids []string) (map[string]snippetReactions, error) {
+	reactions := make(map[string]snippetReactions)
+
+	for _, params := range sqlChunks(ids) {
+		rows, err := db.Query(
+			"SELECT snippet_id,reaction,COUNT(*) FROM snippet_reaction WHERE snippet_id IN ("+
+				sqlPlaceholders(len(params))+") AND comment_id=0 GROUP BY snippet_id,reaction",
+			params...,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var id, reaction string
+			var count int64
+
+			rows.Scan(&id, &reaction, &count)
+
+			if reactions[id] == nil {
+				reactions[id] = make(snippetReactions)
+			}
+			reactions[id][reaction] = count
+		}
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return reactions, nil
+}
+
+// snippetCommentReactionsFetch will count the reactions to each of the
+// comments on a snippet, by comment id
+func snippetCommentReactionsFetch(db *sql.DB, snippetID string) (map[int64]snippetReactions, error) {
+	reactions := make(map[int64]snippetReactions)
+
+	rows, err := db.Query(
+		"SELECT comment_id,reaction,COUNT(*) FROM snippet_reaction WHERE snippet_id=? AND comment_id>0 "+
+			"GROUP BY comment_id,reaction",
+		snippetID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var commentID, count int64
+		var reaction string
+
+		rows.Scan(&commentID, &reaction, &count)
+
+		if reactions[commentID] == nil {
+			reactions[commentID] = make(snippetReactions)
+		}
+		reactions[commentID][reaction] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reactions, nil
+}
@@ -0,0 +1,251 @@
+package summa
+
+import (
+	"database/sql"
+	"fmt"
+	_ "go-sqlite3"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	RELATED_TERMS_MAX = 10
+
+	RELATED_WEIGHT_TAG      = 3.0
+	RELATED_WEIGHT_LANGUAGE = 2.0
+	RELATED_WEIGHT_TERM     = 1.0
+	RELATED_WEIGHT_AUTHOR   = 0.5
+
+	// The most parameters given to a single query, well within
+	// the limit SQLite puts on them
+	SQL_PARAMS_MAX = 500
+)
+
+var (
+	relatedTermRegex = regexp.MustCompile("[A-Za-z_][A-Za-z0-9_]{3,}")
+
+	// Words too common in code and prose to say anything about
+	// whether two snippets are related
+	relatedStopWords = map[string]bool{
+		"this": true, "that": true, "with": true, "from": true, "have": true,
+		"true": true, "false": true, "null": true, "none": true, "self": true,
+		"func": true, "function": true, "return": true, "import": true,
+		"package": true, "const": true, "string": true, "else": true,
+		"class": true, "public": true, "private": true, "static": true,
+		"void": true, "print": true, "then": true, "done": true,
+	}
+)
+
+// relatedScores accumulates a relevance score for each candidate snippet id
+type relatedScores map[string]float64
+
+// add runs a query returning (snippet_id, count) rows and adds count
+// multiplied by weight to the score of each snippet
+func (r relatedScores) add(db *sql.DB, weight float64, query string, params ...interface{}) error {
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var count float64
+		rows.Scan(&id, &count)
+		r[id] += weight * count
+	}
+
+	return rows.Err()
+}
+
+// relatedTerms returns the most frequent distinctive words found in a
+// snippet's description and file contents
+func relatedTerms(snip *snippet) []string {
+	freq := make(map[string]int)
+	var terms []string
+
+	text := snippetSearchDocument(snip.Description, snip.Files)
+	for _, word := range relatedTermRegex.FindAllString(text, -1) {
+		word = strings.ToLower(word)
+		if relatedStopWords[word] {
+			continue
+		}
+		if freq[word] == 0 {
+			terms = append(terms, word)
+		}
+		freq[word]++
+	}
+
+	sort.Strings(terms)
+	sort.SliceStable(terms, func(i, j int) bool {
+		return freq[terms[i]] > freq[terms[j]]
+	})
+
+	if len(terms) > RELATED_TERMS_MAX {
+		terms = terms[:RELATED_TERMS_MAX]
+	}
+
+	return terms
+}
+
+// sqlPlaceholders returns a comma separated list of n parameter placeholders
+func sqlPlaceholders(n int) string {
+	if n < 1 {
+		return ""
+	}
+	return strings.Repeat("?,", n-1) + "?"
+}
+
+// sqlChunks splits ids into lists small enough to be given as the
+// parameters of a single query
+func sqlChunks(ids []string) [][]interface{} {
+	var chunks [][]interface{}
+
+	for start := 0; start < len(ids); start += SQL_PARAMS_MAX {
+		end := start + SQL_PARAMS_MAX
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		chunk := make([]interface{}, end-start)
+		for i, id := range ids[start:end] {
+			chunk[i] = id
+		}
+
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
+
+// snippetsRelated will fetch up to limit snippets similar to the given one, based on
+// shared tags, file languages, search terms and author, with the most similar first
+func snippetsRelated(db *sql.DB, snip *snippet, limit int) (*snippets, error) {
+	var params []interface{}
+	var err error
+	scores := make(relatedScores)
+
+	if len(snip.Tags) > 0 {
+		params = []interface{}{snip.ID}
+		for _, tag := range snip.Tags {
+			params = append(params, tag)
+		}
+
+		err = scores.add(
+			db,
+			RELATED_WEIGHT_TAG,
+			"SELECT snippet_id,COUNT(*) FROM snippet_tag WHERE snippet_id!=? AND tag IN ("+
+				sqlPlaceholders(len(snip.Tags))+") GROUP BY snippet_id",
+			params...,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	languages := make(map[string]bool)
+	params = []interface{}{snip.ID}
+	for _, file := range snip.Files {
+		if !languages[file.Language] {
+			languages[file.Language] = true
+			params = append(params, file.Language)
+		}
+	}
+
+	if len(languages) > 0 {
+		err = scores.add(
+			db,
+			RELATED_WEIGHT_LANGUAGE,
+			"SELECT snippet_id,COUNT(DISTINCT language) FROM snippet_file WHERE snippet_id!=? "+
+				"AND language IN ("+sqlPlaceholders(len(languages))+") GROUP BY snippet_id",
+			params...,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, term := range relatedTerms(snip) {
+		err = scores.add(
+			db,
+			RELATED_WEIGHT_TERM,
+			"SELECT s.snippet_id,1 FROM snippet s JOIN snippet_search ss ON ss.docid=s.search_id "+
+				"WHERE s.snippet_id!=? AND ss.snippet MATCH(?)",
+			snip.ID,
+			term,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Sharing only an author isn't enough to be related
+	// but makes for a good tie breaker
+	if len(scores) > 0 {
+		err = scores.add(
+			db,
+			RELATED_WEIGHT_AUTHOR,
+			"SELECT snippet_id,1 FROM snippet WHERE snippet_id!=? AND username=?",
+			snip.ID,
+			snip.Username,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var ids []string
+	for id, score := range scores {
+		if score > RELATED_WEIGHT_AUTHOR {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+	sort.SliceStable(ids, func(i, j int) bool {
+		return scores[ids[i]] > scores[ids[j]]
+	})
+
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	related := make(snippets, 0, len(ids))
+	if len(ids) == 0 {
+		return &related, nil
+	}
+
+	params = nil
+	for _, id := range ids {
+		params = append(params, id)
+	}
+
+	query := fmt.Sprintf(
+		"SELECT s.snippet_id,s.username,display_name,description,s.created,s.updated,s.template,s.expires,s.deleted,s.archived,s.locked,"+
+			"COUNT(sf.snippet_id) files,COUNT(sc.snippet_id) comments "+
+			"FROM snippet s JOIN user u USING (username) JOIN snippet_file sf USING (snippet_id) "+
+			"LEFT JOIN snippet_comment sc USING (snippet_id) WHERE s.snippet_id IN (%s) AND s.deleted=0 "+
+			"GROUP BY s.snippet_id",
+		sqlPlaceholders(len(ids)),
+	)
+
+	snips, err := snippetsFetchGeneric(db, query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]snippet)
+	for _, s := range *snips {
+		byID[s.ID] = s
+	}
+
+	for _, id := range ids {
+		if s, ok := byID[id]; ok {
+			s.Score = scores[id]
+			related = append(related, s)
+		}
+	}
+
+	return &related, nil
+}
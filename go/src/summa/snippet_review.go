@@ -0,0 +1,228 @@
+package summa
+
+import (
+	"database/sql"
+	_ "go-sqlite3"
+	"sort"
+)
+
+// snippetCommentAnchor ties a comment to a range of lines of a file, as the
+// file was at a specific revision
+type snippetCommentAnchor struct {
+	Filename  string `json:"filename"`
+	LineStart int    `json:"line"`
+	LineEnd   int    `json:"lineEnd"`
+	Revision  string `json:"revision"`
+}
+
+// snippetReviewThread gathers the comments made on the same lines of a file.
+// The lines are those of the current revision, unless they have changed since
+// the comments were made, in which case they are outdated and the lines are
+// those of the revision the comments were made on
+type snippetReviewThread struct {
+	Filename  string          `json:"filename"`
+	LineStart int             `json:"line"`
+	LineEnd   int             `json:"lineEnd"`
+	Revision  string          `json:"revision,omitempty"`
+	Outdated  bool            `json:"outdated,omitempty"`
+	Comments  snippetComments `json:"comments"`
+}
+
+type snippetReviewThreads []*snippetReviewThread
+
+func (t snippetReviewThreads) Len() int      { return len(t) }
+func (t snippetReviewThreads) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+func (t snippetReviewThreads) Less(i, j int) bool {
+	if t[i].Filename != t[j].Filename {
+		return t[i].Filename < t[j].Filename
+	}
+	if t[i].Outdated != t[j].Outdated {
+		return !t[i].Outdated
+	}
+	return t[i].LineStart < t[j].LineStart
+}
+
+// snippetCommentAnchorCreate will store where in a snippet a comment was made
+func snippetCommentAnchorCreate(tx *sql.Tx, comment *snippetComment) error {
+	_, err := tx.Exec(
+		"INSERT INTO snippet_comment_anchor VALUES (?,?,?,?,?,?)",
+		comment.ID,
+		comment.SnippetID,
+		comment.Anchor.Filename,
+		comment.Anchor.LineStart,
+		comment.Anchor.LineEnd,
+		comment.Anchor.Revision,
+	)
+
+	return err
+}
+
+// snippetCommentAnchorsFetch will fetch where in a snippet each of its
+// comments that were made on lines of a file were made, by comment id
+func snippetCommentAnchorsFetch(db *sql.DB, id string) (map[int64]*snippetCommentAnchor, error) {
+	anchors := make(map[int64]*snippetCommentAnchor)
+
+	rows, err := db.Query(
+		"SELECT comment_id,filename,line_start,line_end,revision FROM snippet_comment_anchor "+
+			"WHERE snippet_id=?",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var commentID int64
+		var anchor snippetCommentAnchor
+
+		rows.Scan(
+			&commentID,
+			&anchor.Filename,
+			&anchor.LineStart,
+			&anchor.LineEnd,
+			&anchor.Revision,
+		)
+
+		anchors[commentID] = &anchor
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return anchors, nil
+}
+
+// snippetReviewThreadsFetch will take the threads of comments made on lines
+// of a snippet's files out of its comments, grouped by file and line. The
+// remaining comments are returned along with them
+func snippetReviewThreadsFetch(db *sql.DB, id string, comments snippetComments) (snippetComments, snippetReviewThreads, error) {
+	anchors, err := snippetCommentAnchorsFetch(db, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	general := make(snippetComments, 0)
+	threads := make(snippetReviewThreads, 0)
+	byLines := make(map[snippetCommentAnchor]*snippetReviewThread)
+
+	// Each file is read once for every revision comments were made on
+	contents := make(map[[2]string]*string)
+	read := func(rev, filename string) (*string, error) {
+		key := [2]string{rev, filename}
+		if c, ok := contents[key]; ok {
+			return c, nil
+		}
+
+		b, err := repoReadFile(id, rev, filename)
+		if err != nil {
+			return nil, err
+		}
+
+		var c *string
+		if b != nil && !IsBinary(b) {
+			s := string(b)
+			c = &s
+		}
+
+		contents[key] = c
+		return c, nil
+	}
+
+	// The lines of a file are matched up with the current revision
+	// once for every revision comments were made on
+	matched := make(map[[2]string][]int)
+	lineMatches := func(rev, filename string) ([]int, error) {
+		key := [2]string{rev, filename}
+		if m, ok := matched[key]; ok {
+			return m, nil
+		}
+
+		old, err := read(rev, filename)
+		if err != nil {
+			return nil, err
+		}
+
+		current, err := read("HEAD", filename)
+		if err != nil {
+			return nil, err
+		}
+
+		var m []int
+		if old != nil && current != nil {
+			m = mergeMatches(diffSplitLines(*old), diffSplitLines(*current))
+		}
+
+		matched[key] = m
+		return m, nil
+	}
+
+	for _, comment := range comments {
+		anchor := anchors[comment.ID]
+		if anchor == nil {
+			general = append(general, comment)
+			continue
+		}
+
+		comment.Anchor = anchor
+
+		// Outdated comments are only grouped with others made on the
+		// same revision
+		key := *anchor
+		outdated := true
+
+		matches, err := lineMatches(anchor.Revision, anchor.Filename)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if matches != nil {
+			start, end, ok := reviewMapLines(matches, anchor.LineStart, anchor.LineEnd)
+			if ok {
+				key = snippetCommentAnchor{anchor.Filename, start, end, ""}
+				outdated = false
+			}
+		}
+
+		thread := byLines[key]
+		if thread == nil {
+			thread = &snippetReviewThread{
+				Filename:  key.Filename,
+				LineStart: key.LineStart,
+				LineEnd:   key.LineEnd,
+				Revision:  key.Revision,
+				Outdated:  outdated,
+				Comments:  make(snippetComments, 0),
+			}
+
+			byLines[key] = thread
+			threads = append(threads, thread)
+		}
+
+		thread.Comments = append(thread.Comments, comment)
+	}
+
+	sort.Stable(threads)
+
+	return general, threads, nil
+}
+
+// reviewMapLines will find where a range of lines, counting from one, of
+// one version of a file is in another, given where each line of the first
+// version is in the second, counting from zero, or -1 for lines that were
+// changed or removed. The lines are only found if none of them changed and
+// nothing was inserted between them
+func reviewMapLines(matches []int, start, end int) (int, int, bool) {
+	if start < 1 || end < start || end > len(matches) {
+		return start, end, false
+	}
+
+	for i := start; i <= end; i++ {
+		if matches[i-1] < 0 || (i > start && matches[i-1] != matches[i-2]+1) {
+			return start, end, false
+		}
+	}
+
+	return matches[start-1] + 1, matches[end-1] + 1, true
+}
@@ -0,0 +1,39 @@
+package summa
+
+import (
+	"testing"
+)
+
+func TestReviewMapLines(t *testing.T) {
+	tests := []struct {
+		name       string
+		old, new   string
+		start, end int
+		wantStart  int
+		wantEnd    int
+		found      bool
+	}{
+		{"unchanged", "a\nb\nc\nd\n", "a\nb\nc\nd\n", 2, 3, 2, 3, true},
+		{"lines inserted before", "a\nb\nc\nd\n", "x\ny\na\nb\nc\nd\n", 2, 3, 4, 5, true},
+		{"lines removed before", "a\nb\nc\nd\n", "b\nc\nd\n", 2, 3, 1, 2, true},
+		{"lines inserted after", "a\nb\nc\nd\n", "a\nb\nc\nX\nd\n", 2, 3, 2, 3, true},
+		{"line changed", "a\nb\nc\nd\n", "a\nB\nc\nd\n", 2, 3, 2, 3, false},
+		{"line inserted between", "a\nb\nc\nd\n", "a\nb\nX\nc\nd\n", 2, 3, 2, 3, false},
+		{"line removed", "a\nb\nc\nd\n", "a\nd\n", 2, 2, 2, 2, false},
+		{"single line moved down", "a\nb\n", "x\na\nb\n", 2, 2, 3, 3, true},
+		{"past the end", "a\nb\n", "a\nb\n", 2, 3, 2, 3, false},
+		{"empty file", "", "a\n", 1, 1, 1, 1, false},
+	}
+
+	for _, test := range tests {
+		matches := mergeMatches(diffSplitLines(test.old), diffSplitLines(test.new))
+
+		start, end, found := reviewMapLines(matches, test.start, test.end)
+		if start != test.wantStart || end != test.wantEnd || found != test.found {
+			t.Errorf(
+				"%s: got %d-%d %v, want %d-%d %v",
+				test.name, start, end, found, test.wantStart, test.wantEnd, test.found,
+			)
+		}
+	}
+}
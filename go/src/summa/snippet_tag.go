@@ -0,0 +1,137 @@
+package summa
+
+import (
+	"database/sql"
+	_ "go-sqlite3"
+	"regexp"
+)
+
+const (
+	SNIPPET_TAGS_MAX = 10
+)
+
+var (
+	snippetTagRegex = regexp.MustCompile("^[a-z0-9][a-z0-9_.+#-]{0,31}$")
+)
+
+type tagCount struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
+
+type tagCounts []tagCount
+
+// snippetTagIsValid returns true if the given tag is lower case and
+// made up of characters that are safe to use in a URL
+func snippetTagIsValid(tag string) bool {
+	return snippetTagRegex.MatchString(tag)
+}
+
+// snippetTagsFetch will fetch the tags for a specific snippet
+func snippetTagsFetch(db *sql.DB, id string) ([]string, error) {
+	var tags []string
+
+	rows, err := db.Query(
+		"SELECT tag FROM snippet_tag WHERE snippet_id=? ORDER BY tag",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag string
+		rows.Scan(&tag)
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// snippetsTagsFetch will fetch the tags for each of a list of snippets,
+// by snippet id
+func snippetsTagsFetch(db *sql.DB, ids []string) (map[string][]string, error) {
+	tags := make(map[string][]string)
+
+	for _, params := range sqlChunks(ids) {
+		rows, err := db.Query(
+			"SELECT snippet_id,tag FROM snippet_tag WHERE snippet_id IN ("+
+				sqlPlaceholders(len(params))+") ORDER BY tag",
+			params...,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var id, tag string
+			rows.Scan(&id, &tag)
+			tags[id] = append(tags[id], tag)
+		}
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return tags, nil
+}
+
+// snippetTagsSet will replace the tags of a specific snippet as part
+// of a larger transaction
+func snippetTagsSet(tx *sql.Tx, id string, tags []string) error {
+	_, err := tx.Exec("DELETE FROM snippet_tag WHERE snippet_id=?", id)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		_, err = tx.Exec("INSERT INTO snippet_tag VALUES (?,?)", id, tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tagsFetch will fetch the tags in use along with the number of snippets
+// carrying each one, optionally limited to tags starting with a prefix
+func tagsFetch(db *sql.DB, prefix string, limit int) (tagCounts, error) {
+	var tags tagCounts
+
+	rows, err := db.Query(
+		"SELECT tag,COUNT(*) count FROM snippet_tag JOIN snippet USING (snippet_id) "+
+			"WHERE deleted=0 AND substr(tag,1,?)=? GROUP BY tag ORDER BY count DESC, tag LIMIT ?",
+		len(prefix),
+		prefix,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag tagCount
+
+		rows.Scan(
+			&tag.Tag,
+			&tag.Count,
+		)
+
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,143 @@
+package summa
+
+import (
+	"database/sql"
+	_ "go-sqlite3"
+)
+
+// snippetTransfer is a request to hand a snippet over to a new owner,
+// which takes effect once the new owner accepts it
+type snippetTransfer struct {
+	SnippetID   string `json:"snippetId"`
+	Description string `json:"description"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Created     int64  `json:"created"`
+}
+
+type snippetTransfers []snippetTransfer
+
+// snippetTransferFetch will fetch the pending transfer of a specific snippet
+func snippetTransferFetch(db *sql.DB, id string) (*snippetTransfer, error) {
+	var t snippetTransfer
+
+	row := db.QueryRow(
+		"SELECT snippet_id,description,from_username,to_username,t.created FROM "+
+			"snippet_transfer t JOIN snippet USING (snippet_id) WHERE snippet_id=? AND deleted=0",
+		id,
+	)
+
+	err := row.Scan(
+		&t.SnippetID,
+		&t.Description,
+		&t.From,
+		&t.To,
+		&t.Created,
+	)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// snippetTransfersFetch will fetch the pending transfers to or from a specific user
+func snippetTransfersFetch(db *sql.DB, username string) (snippetTransfers, error) {
+	transfers := make(snippetTransfers, 0)
+
+	rows, err := db.Query(
+		"SELECT snippet_id,description,from_username,to_username,t.created FROM "+
+			"snippet_transfer t JOIN snippet USING (snippet_id) WHERE deleted=0 AND "+
+			"(to_username=? OR from_username=?) ORDER BY t.created DESC",
+		username,
+		username,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t snippetTransfer
+
+		rows.Scan(
+			&t.SnippetID,
+			&t.Description,
+			&t.From,
+			&t.To,
+			&t.Created,
+		)
+
+		transfers = append(transfers, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return transfers, nil
+}
+
+// snippetTransferRequest will ask a user to take over a snippet, replacing
+// any transfer of the snippet still waiting to be accepted
+func snippetTransferRequest(db *sql.DB, t *snippetTransfer) error {
+	t.Created = UnixMilliseconds()
+
+	_, err := db.Exec(
+		"INSERT OR REPLACE INTO snippet_transfer VALUES (?,?,?,?)",
+		t.SnippetID,
+		t.From,
+		t.To,
+		t.Created,
+	)
+
+	return err
+}
+
+// snippetTransferCancel will withdraw the pending transfer of a snippet
+func snippetTransferCancel(db *sql.DB, id string) error {
+	_, err := db.Exec("DELETE FROM snippet_transfer WHERE snippet_id=?", id)
+
+	return err
+}
+
+// snippetTransferAccept will make the user a snippet was transferred to its
+// owner. They are no longer listed as a co-author, having become the owner
+func snippetTransferAccept(db *sql.DB, t *snippetTransfer) error {
+	var err error
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer (func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	})()
+
+	_, err = tx.Exec("UPDATE snippet SET username=? WHERE snippet_id=?", t.To, t.SnippetID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"DELETE FROM snippet_author WHERE snippet_id=? AND username=?",
+		t.SnippetID,
+		t.To,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM snippet_transfer WHERE snippet_id=?", t.SnippetID)
+
+	return err
+}
@@ -4,10 +4,62 @@ import (
 	"database/sql"
 	"fmt"
 	_ "go-sqlite3"
+	"regexp"
+	"strings"
+	"time"
 )
 
 type snippets []snippet
 
+// snippetsFilter holds the optional criteria used to narrow down
+// a list of snippets
+type snippetsFilter struct {
+	Username  string
+	Tag       string
+	Language  string
+	Templates bool
+
+	// Archived snippets are left out of the feeds of new
+	// snippets, but can still be searched for
+	HideArchived bool
+}
+
+// whereClause combines the given conditions with those of the filter into
+// a WHERE clause, returning it along with the parameters it needs
+func (f *snippetsFilter) whereClause(conds []string, params []interface{}) (string, []interface{}) {
+	// Snippets in the trash are never listed
+	conds = append(conds, "s.deleted=0")
+
+	if f != nil {
+		// Snippets co-authored by the user are theirs too
+		if f.Username != "" {
+			conds = append(conds, "(s.username=? OR s.snippet_id IN "+
+				"(SELECT snippet_id FROM snippet_author WHERE username=?))")
+			params = append(params, f.Username, f.Username)
+		}
+
+		if f.Tag != "" {
+			conds = append(conds, "s.snippet_id IN (SELECT snippet_id FROM snippet_tag WHERE tag=?)")
+			params = append(params, f.Tag)
+		}
+
+		if f.Language != "" {
+			conds = append(conds, "s.snippet_id IN (SELECT snippet_id FROM snippet_file WHERE language=?)")
+			params = append(params, f.Language)
+		}
+
+		if f.Templates {
+			conds = append(conds, "s.template=1")
+		}
+
+		if f.HideArchived {
+			conds = append(conds, "s.archived=0")
+		}
+	}
+
+	return "WHERE " + strings.Join(conds, " AND "), params
+}
+
 // snippetsFetchGeneric will fetch snippets from the database
 func snippetsFetchGeneric(db *sql.DB, query string, params []interface{}) (*snippets, error) {
 	var snips snippets
@@ -32,6 +84,11 @@ func snippetsFetchGeneric(db *sql.DB, query string, params []interface{}) (*snip
 			&snip.Description,
 			&snip.Created,
 			&snip.Updated,
+			&snip.Template,
+			&snip.ExpiresAt,
+			&snip.Deleted,
+			&snip.Archived,
+			&snip.Locked,
 			&snip.NumFiles,
 			&snip.NumComments,
 		)
@@ -42,38 +99,110 @@ func snippetsFetchGeneric(db *sql.DB, query string, params []interface{}) (*snip
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
+	rows.Close()
+
+	ids := make([]string, len(snips))
+	for i := range snips {
+		ids[i] = snips[i].ID
+	}
+
+	tags, err := snippetsTagsFetch(db, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	authors, err := snippetsAuthorsFetch(db, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	reactions, err := snippetsReactionsFetch(db, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range snips {
+		snips[i].Tags = tags[snips[i].ID]
+		snips[i].Authors = authors[snips[i].ID]
+		snips[i].Reactions = reactions[snips[i].ID]
+	}
 
 	return &snips, nil
 }
 
-// snippetsSearch will fetch snippets using a search term, sorted by the given value
-func snippetsSearch(db *sql.DB, orderBy, term string) (*snippets, error) {
+// snippetsSearch will fetch snippets using a search term, sorted by the given value and
+// optionally filtered
+func snippetsSearch(db *sql.DB, orderBy, term string, filter *snippetsFilter) (*snippets, error) {
+	whereClause, params := filter.whereClause(
+		[]string{"ss.snippet MATCH(?)"},
+		[]interface{}{term},
+	)
+
 	query := fmt.Sprintf(
-		"SELECT s.snippet_id,s.username,u.display_name,s.description,s.created,s.updated,"+
+		"SELECT s.snippet_id,s.username,u.display_name,s.description,s.created,s.updated,s.template,s.expires,s.deleted,s.archived,s.locked,"+
 			"COUNT(sf.snippet_id) files,COUNT(sc.snippet_id) comments FROM snippet s JOIN "+
 			"user u ON u.username=s.username JOIN snippet_file sf ON s.snippet_id=sf.snippet_id "+
 			"JOIN snippet_search ss ON ss.docid=s.search_id LEFT JOIN snippet_comment sc ON "+
-			"s.snippet_id=sc.snippet_id WHERE ss.snippet MATCH(?) GROUP BY s.snippet_id ORDER BY %s",
+			"s.snippet_id=sc.snippet_id %s GROUP BY s.snippet_id ORDER BY %s",
+		whereClause,
 		orderBy,
 	)
 
-	params := []interface{}{term}
-
 	return snippetsFetchGeneric(db, query, params)
 }
 
-// snippetsFetch will fetch snippets in a given range, sorted by the given value and optionally
-// filtered by username
-func snippetsFetch(db *sql.DB, start, limit float64, orderBy, username string) (*snippets, error) {
-	var params []interface{}
+// snippetsGrep will fetch snippets having file contents that match a regular expression,
+// sorted by the given value and optionally filtered. Scanning stops once the deadline has
+// passed or max snippets have matched, in which case the returned bool will be true
+func snippetsGrep(db *sql.DB, orderBy string, filter *snippetsFilter, re *regexp.Regexp, deadline time.Time, max int) (*snippets, bool, error) {
+	var matched snippets
 
-	whereClause := ""
-	if username != "" {
-		whereClause = "WHERE s.username=?"
-		params = append(params, username)
+	whereClause, params := filter.whereClause(nil, nil)
+
+	query := fmt.Sprintf(
+		"SELECT s.snippet_id,s.username,display_name,description,s.created,s.updated,s.template,s.expires,s.deleted,s.archived,s.locked,"+
+			"COUNT(sf.snippet_id) files,COUNT(sc.snippet_id) comments "+
+			"FROM snippet s JOIN user u USING (username) JOIN snippet_file sf USING (snippet_id) "+
+			"LEFT JOIN snippet_comment sc USING (snippet_id) %s GROUP BY s.snippet_id ORDER BY %s",
+		whereClause,
+		orderBy,
+	)
+
+	snips, err := snippetsFetchGeneric(db, query, params)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, snip := range *snips {
+		if len(matched) >= max || time.Now().After(deadline) {
+			return &matched, true, nil
+		}
+
+		var truncated bool
+		snip.Matches, truncated, err = snippetGrepFiles(db, snip.ID, re, deadline)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if len(snip.Matches) > 0 {
+			matched = append(matched, snip)
+		}
+
+		if truncated {
+			return &matched, true, nil
+		}
 	}
+
+	return &matched, false, nil
+}
+
+// snippetsFetch will fetch snippets in a given range, sorted by the given value and optionally
+// filtered
+func snippetsFetch(db *sql.DB, start, limit float64, orderBy string, filter *snippetsFilter) (*snippets, error) {
+	whereClause, params := filter.whereClause(nil, nil)
+
 	query := fmt.Sprintf(
-		"SELECT s.snippet_id,s.username,display_name,description,s.created,s.updated,"+
+		"SELECT s.snippet_id,s.username,display_name,description,s.created,s.updated,s.template,s.expires,s.deleted,s.archived,s.locked,"+
 			"COUNT(sf.snippet_id) files,COUNT(sc.snippet_id) comments "+
 			"FROM snippet s JOIN user u USING (username) JOIN snippet_file sf USING (snippet_id) "+
 			"LEFT JOIN snippet_comment sc USING (snippet_id) %s GROUP BY s.snippet_id "+
@@ -87,16 +216,149 @@ func snippetsFetch(db *sql.DB, start, limit float64, orderBy, username string) (
 	return snippetsFetchGeneric(db, query, params)
 }
 
-// snippetsUnread will return unread snippets for a specific user
-func snippetsUnread(db *sql.DB, username string) (*snippets, error) {
-	query := "SELECT s.snippet_id,s.username,u.display_name,s.description,s.created,s.updated," +
+// snippetsUnread will return unread snippets for a specific user, optionally filtered
+func snippetsUnread(db *sql.DB, username string, filter *snippetsFilter) (*snippets, error) {
+	whereClause, params := filter.whereClause(
+		[]string{"sv.snippet_id IS NULL"},
+		[]interface{}{username},
+	)
+
+	query := "SELECT s.snippet_id,s.username,u.display_name,s.description,s.created,s.updated,s.template,s.expires,s.deleted,s.archived,s.locked," +
 		"COUNT(sf.snippet_id) files,COUNT(sc.snippet_id) comments FROM snippet s JOIN " +
 		"user u ON u.username=s.username JOIN snippet_file sf ON s.snippet_id=sf.snippet_id " +
 		"LEFT JOIN snippet_comment sc ON s.snippet_id=sc.snippet_id LEFT JOIN snippet_view sv " +
-		"ON s.snippet_id=sv.snippet_id AND sv.username=? WHERE sv.snippet_id IS NULL " +
+		"ON s.snippet_id=sv.snippet_id AND sv.username=? " + whereClause + " " +
 		"GROUP BY s.snippet_id"
 
-	params := []interface{}{username}
+	return snippetsFetchGeneric(db, query, params)
+}
+
+// snippetsSearchUnread will return snippets matching a search term that have not
+// yet been read by a specific user
+func snippetsSearchUnread(db *sql.DB, username, term string) (*snippets, error) {
+	query := "SELECT s.snippet_id,s.username,u.display_name,s.description,s.created,s.updated,s.template,s.expires,s.deleted,s.archived,s.locked," +
+		"COUNT(sf.snippet_id) files,COUNT(sc.snippet_id) comments FROM snippet s JOIN " +
+		"user u ON u.username=s.username JOIN snippet_file sf ON s.snippet_id=sf.snippet_id " +
+		"JOIN snippet_search ss ON ss.docid=s.search_id LEFT JOIN snippet_comment sc ON " +
+		"s.snippet_id=sc.snippet_id LEFT JOIN snippet_view sv ON s.snippet_id=sv.snippet_id " +
+		"AND sv.username=? WHERE ss.snippet MATCH(?) AND sv.snippet_id IS NULL AND s.deleted=0 " +
+		"AND s.archived=0 " +
+		"GROUP BY s.snippet_id ORDER BY s.updated DESC, s.created DESC"
+
+	params := []interface{}{username, term}
 
 	return snippetsFetchGeneric(db, query, params)
 }
+
+// snippetsExpired will return the ids of snippets due to expire by the given time
+func snippetsExpired(db *sql.DB, now int64) ([]string, error) {
+	var ids []string
+
+	rows, err := db.Query(
+		"SELECT snippet_id FROM snippet WHERE expires>0 AND expires<=? AND deleted=0 ORDER BY expires",
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// snippetsTrash will return the snippets in the trash, most recently deleted
+// first, optionally only those of a specific user
+func snippetsTrash(db *sql.DB, username string) (*snippets, error) {
+	conds := []string{"s.deleted>0"}
+	var params []interface{}
+
+	if username != "" {
+		conds = append(conds, "s.username=?")
+		params = append(params, username)
+	}
+
+	query := "SELECT s.snippet_id,s.username,u.display_name,s.description,s.created,s.updated," +
+		"s.template,s.expires,s.deleted,s.archived,s.locked,COUNT(sf.snippet_id) files,COUNT(sc.snippet_id) comments FROM snippet s " +
+		"JOIN user u ON u.username=s.username JOIN snippet_file sf ON s.snippet_id=sf.snippet_id " +
+		"LEFT JOIN snippet_comment sc ON s.snippet_id=sc.snippet_id WHERE " +
+		strings.Join(conds, " AND ") + " GROUP BY s.snippet_id ORDER BY s.deleted DESC"
+
+	return snippetsFetchGeneric(db, query, params)
+}
+
+// snippetsPurgeable will return the ids of snippets that were
+// deleted before the given time
+func snippetsPurgeable(db *sql.DB, before int64) ([]string, error) {
+	var ids []string
+
+	rows, err := db.Query(
+		"SELECT snippet_id FROM snippet WHERE deleted>0 AND deleted<=? ORDER BY deleted",
+		before,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// snippetsCountLanguages will count the snippets having files in each language, out of
+// those matching the given conditions and filter. The join is added to the query so the
+// conditions can refer to tables other than snippet and snippet_file
+func snippetsCountLanguages(db *sql.DB, join string, conds []string, params []interface{}, filter *snippetsFilter) (languageCounts, error) {
+	var counts languageCounts
+
+	whereClause, params := filter.whereClause(conds, params)
+
+	query := fmt.Sprintf(
+		"SELECT sf.language,COUNT(DISTINCT s.snippet_id) count FROM snippet s "+
+			"JOIN snippet_file sf ON s.snippet_id=sf.snippet_id %s %s "+
+			"GROUP BY sf.language ORDER BY count DESC, sf.language",
+		join,
+		whereClause,
+	)
+
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var count languageCount
+
+		rows.Scan(
+			&count.Language,
+			&count.Count,
+		)
+
+		counts = append(counts, count)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
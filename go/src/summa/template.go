@@ -0,0 +1,50 @@
+package summa
+
+import (
+	"regexp"
+	"sort"
+)
+
+// Placeholders in templates look like {{name}}, optionally
+// with spaces inside the braces
+var templatePlaceholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// templateSubstitute will replace the placeholders in s with the values of
+// the variables they name. Placeholders without a value are left as they are
+func templateSubstitute(s string, vars map[string]string) string {
+	return templatePlaceholderPattern.ReplaceAllStringFunc(s, func(placeholder string) string {
+		name := templatePlaceholderPattern.FindStringSubmatch(placeholder)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+// templateVariables returns the sorted names of the placeholders used in
+// the description, filenames and text files of a template
+func templateVariables(snip *snippet) []string {
+	names := make(map[string]bool)
+
+	texts := []string{snip.Description}
+	for _, file := range snip.Files {
+		texts = append(texts, file.Filename)
+		if !file.Binary {
+			texts = append(texts, file.Contents)
+		}
+	}
+
+	for _, text := range texts {
+		for _, match := range templatePlaceholderPattern.FindAllStringSubmatch(text, -1) {
+			names[match[1]] = true
+		}
+	}
+
+	vars := make([]string, 0, len(names))
+	for name := range names {
+		vars = append(vars, name)
+	}
+	sort.Strings(vars)
+
+	return vars
+}
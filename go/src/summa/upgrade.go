@@ -0,0 +1,169 @@
+package summa
+
+import (
+	"database/sql"
+	"fmt"
+	_ "go-sqlite3"
+)
+
+// upgradeColumn is a column added to a table after the table was first
+// created. Databases created before then are given the column, after which
+// the statements are run to index it or fill it in
+type upgradeColumn struct {
+	Table      string
+	Column     string
+	Definition string
+	Statements []string
+}
+
+// The columns added to existing tables, oldest first
+var upgradeColumns = []upgradeColumn{
+	{
+		Table:      "snippet",
+		Column:     "template",
+		Definition: "INTEGER NOT NULL DEFAULT 0",
+	},
+	{
+		Table:      "snippet",
+		Column:     "expires",
+		Definition: "INTEGER NOT NULL DEFAULT 0",
+		Statements: []string{
+			`CREATE INDEX "idx_snippet_expires" ON "snippet" ("expires")`,
+		},
+	},
+	{
+		Table:      "user",
+		Column:     "expire_after",
+		Definition: "INTEGER NOT NULL DEFAULT 0",
+	},
+	{
+		Table:      "snippet",
+		Column:     "deleted",
+		Definition: "INTEGER NOT NULL DEFAULT 0",
+		Statements: []string{
+			`CREATE INDEX "idx_snippet_deleted" ON "snippet" ("deleted")`,
+		},
+	},
+	{
+		Table:      "snippet",
+		Column:     "archived",
+		Definition: "INTEGER NOT NULL DEFAULT 0",
+	},
+	{
+		Table:      "snippet",
+		Column:     "locked",
+		Definition: "INTEGER NOT NULL DEFAULT 0",
+	},
+	{
+		// Every comment made before there were replies starts its own
+		// thread, which has been read by whoever had read its snippet
+		Table:      "snippet_comment",
+		Column:     "parent_id",
+		Definition: "INTEGER NOT NULL DEFAULT 0",
+		Statements: []string{
+			`CREATE INDEX "idx_snippet_comment_parent_id" ON "snippet_comment" ("parent_id")`,
+			`CREATE TABLE IF NOT EXISTS "snippet_thread_view" (` +
+				`"snippet_id" TEXT, "comment_id" INTEGER, "username" TEXT, ` +
+				`PRIMARY KEY ("comment_id", "username"))`,
+			`CREATE INDEX IF NOT EXISTS "idx_snippet_thread_view_snippet_id" ON "snippet_thread_view" ("snippet_id")`,
+			`INSERT OR IGNORE INTO "snippet_thread_view" ` +
+				`SELECT sc.snippet_id,sc.comment_id,sv.username FROM snippet_comment sc ` +
+				`JOIN snippet_view sv ON sv.snippet_id=sc.snippet_id`,
+		},
+	},
+	{
+		Table:      "snippet_comment",
+		Column:     "deleted",
+		Definition: "INTEGER NOT NULL DEFAULT 0",
+	},
+}
+
+// upgradeDatabase opens the Summa database and brings the tables in it up to
+// date with the columns added since they were created
+func upgradeDatabase() error {
+	db, err := sql.Open("sqlite3", config.DBFile())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	for _, c := range upgradeColumns {
+		columns, err := upgradeTableColumns(db, c.Table)
+		if err != nil {
+			return err
+		}
+
+		// Tables that don't exist yet have nothing to upgrade
+		if len(columns) == 0 || columns[c.Column] {
+			continue
+		}
+
+		err = upgradeAddColumn(db, c)
+		if err != nil {
+			return fmt.Errorf("Could not add %s.%s: %s", c.Table, c.Column, err)
+		}
+
+		infoLog.Printf("Added column %s.%s", c.Table, c.Column)
+	}
+
+	return nil
+}
+
+// upgradeTableColumns returns the names of the columns of a table, which
+// are none if there is no such table
+func upgradeTableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	columns := make(map[string]bool)
+
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%q)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int64
+		var name, columnType string
+		var defaultValue sql.NullString
+
+		rows.Scan(
+			&cid,
+			&name,
+			&columnType,
+			&notNull,
+			&defaultValue,
+			&pk,
+		)
+
+		columns[name] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return columns, nil
+}
+
+// upgradeAddColumn will add a column to its table and run the statements
+// that go with it, all or nothing
+func upgradeAddColumn(db *sql.DB, c upgradeColumn) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	statements := append(
+		[]string{fmt.Sprintf("ALTER TABLE %q ADD COLUMN %q %s", c.Table, c.Column, c.Definition)},
+		c.Statements...,
+	)
+
+	for _, statement := range statements {
+		_, err = tx.Exec(statement)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
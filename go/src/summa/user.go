@@ -9,6 +9,7 @@ type User struct {
 	Username    string `json:"username"`
 	DisplayName string `json:"displayName"`
 	Email       string `json:"email"`
+	ExpireAfter int64  `json:"expireAfter"`
 }
 
 func userExists(db *sql.DB, username string) (bool, error) {
@@ -29,7 +30,7 @@ func userFetch(db *sql.DB, username string) (*User, error) {
 	var u User
 
 	row := db.QueryRow(
-		"SELECT username,display_name,email FROM user WHERE username=?",
+		"SELECT username,display_name,email,expire_after FROM user WHERE username=?",
 		username,
 	)
 
@@ -37,6 +38,7 @@ func userFetch(db *sql.DB, username string) (*User, error) {
 		&u.Username,
 		&u.DisplayName,
 		&u.Email,
+		&u.ExpireAfter,
 	)
 
 	switch {
@@ -51,10 +53,11 @@ func userFetch(db *sql.DB, username string) (*User, error) {
 
 func userCreate(db *sql.DB, u *User) error {
 	_, err := db.Exec(
-		"INSERT INTO user VALUES (?,?,?)",
+		"INSERT INTO user (username,display_name,email,expire_after) VALUES (?,?,?,?)",
 		u.Username,
 		u.DisplayName,
 		u.Email,
+		u.ExpireAfter,
 	)
 
 	return err
@@ -62,9 +65,10 @@ func userCreate(db *sql.DB, u *User) error {
 
 func userUpdate(db *sql.DB, u *User) error {
 	_, err := db.Exec(
-		"UPDATE user SET display_name=?,email=? WHERE username=?",
+		"UPDATE user SET display_name=?,email=?,expire_after=? WHERE username=?",
 		u.DisplayName,
 		u.Email,
+		u.ExpireAfter,
 		u.Username,
 	)
 
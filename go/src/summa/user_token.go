@@ -0,0 +1,138 @@
+package summa
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	_ "go-sqlite3"
+)
+
+const (
+	USER_TOKEN_BYTES = 20
+)
+
+// userToken is a long lived token a user can hand to scripts and other
+// tools to download snippets without signing in. Only a hash of the token
+// is stored, so the token itself is only known when it is created
+type userToken struct {
+	Token    string `json:"token,omitempty"`
+	ID       int64  `json:"id"`
+	Username string `json:"-"`
+	Name     string `json:"name"`
+	Created  int64  `json:"created"`
+	LastUsed int64  `json:"lastUsed"`
+}
+
+type userTokens []userToken
+
+// userTokenCreate generates a random token for a user and stores
+// it in the database
+func userTokenCreate(db *sql.DB, t *userToken) error {
+	buf := make([]byte, USER_TOKEN_BYTES)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return err
+	}
+
+	t.Token = fmt.Sprintf("%x", buf)
+	t.Created = UnixMilliseconds()
+
+	result, err := db.Exec(
+		"INSERT INTO user_token (token_hash,username,name,created,last_used) VALUES (?,?,?,?,0)",
+		userTokenHash(t.Token),
+		t.Username,
+		t.Name,
+		t.Created,
+	)
+	if err != nil {
+		return err
+	}
+
+	t.ID, err = result.LastInsertId()
+
+	return err
+}
+
+// userTokenHash returns the hash a token is stored as
+func userTokenHash(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
+
+// userTokenUsername returns the username a token belongs to, or an empty
+// string if there is no such token, recording that the token was used
+func userTokenUsername(db *sql.DB, token string) (string, error) {
+	var username string
+
+	hash := userTokenHash(token)
+
+	row := db.QueryRow("SELECT username FROM user_token WHERE token_hash=?", hash)
+	err := row.Scan(&username)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return "", nil
+	case err != nil:
+		return "", err
+	}
+
+	_, err = db.Exec(
+		"UPDATE user_token SET last_used=? WHERE token_hash=?",
+		UnixMilliseconds(),
+		hash,
+	)
+
+	return username, err
+}
+
+// userTokensFetch will fetch the tokens of a specific user, without the
+// tokens themselves
+func userTokensFetch(db *sql.DB, username string) (userTokens, error) {
+	var tokens userTokens
+
+	rows, err := db.Query(
+		"SELECT token_id,username,name,created,last_used FROM user_token "+
+			"WHERE username=? ORDER BY created",
+		username,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t userToken
+
+		rows.Scan(
+			&t.ID,
+			&t.Username,
+			&t.Name,
+			&t.Created,
+			&t.LastUsed,
+		)
+
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// userTokenDelete removes a token, given its id, from a user's tokens
+func userTokenDelete(db *sql.DB, username, id string) (bool, error) {
+	result, err := db.Exec(
+		"DELETE FROM user_token WHERE username=? AND token_id=?",
+		username,
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := result.RowsAffected()
+
+	return count > 0, err
+}
@@ -1,11 +1,13 @@
 package summa
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
+	"unicode/utf8"
 )
 
 // FileExists returns true if the given path
@@ -35,6 +37,24 @@ func IsFile(path string) bool {
 	return exists && stat.Mode().IsRegular()
 }
 
+// IsBinary returns true if the given data, usually the start of
+// a file, doesn't look like UTF-8 encoded text
+func IsBinary(data []byte) bool {
+	if bytes.IndexByte(data, 0) >= 0 {
+		return true
+	}
+
+	// The data may end part way through a multibyte character
+	for i := 0; i < utf8.UTFMax && len(data) > 0; i++ {
+		if utf8.Valid(data) {
+			return false
+		}
+		data = data[:len(data)-1]
+	}
+
+	return len(data) > 0
+}
+
 // ResolvePath returns an absolute path generated from
 // resolving symbolic links and relative path parts
 func ResolvePath(path string, basePath string) (string, error) {